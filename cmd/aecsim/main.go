@@ -0,0 +1,202 @@
+// Command aecsim runs the echo canceller offline on a far-end/near-end WAV
+// pair and reports how much echo it removed.
+//
+// The far-end file is what the loudspeaker played, the near-end file what
+// the microphone captured, both 16-bit PCM. A synthetic pair (speech-like
+// playback, a room echo path with delay, microphone noise and an optional
+// near-end talker) can be generated first:
+//
+//	go run ./cmd/aecsim -generate -delay 120ms -doubletalk
+//	go run ./cmd/aecsim -far far.wav -near near.wav -out out.wav
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+
+	"websocket_client_chat/pkg/aec"
+	"websocket_client_chat/pkg/utils"
+)
+
+const (
+	sampleRate    = 16000
+	chunkSamples  = 3200 // 200ms, as delivered by the recorder
+	playbackBlock = 256  // Samples per simulated playback callback
+)
+
+func main() {
+	farPath := flag.String("far", "far.wav", "Far-end (playback) WAV file")
+	nearPath := flag.String("near", "near.wav", "Near-end (microphone) WAV file")
+	outPath := flag.String("out", "out.wav", "Where the echo-cancelled audio is written")
+	generate := flag.Bool("generate", false, "Write a synthetic far/near pair before processing")
+	duration := flag.Duration("duration", 12*time.Second, "Length of the synthetic pair")
+	delay := flag.Duration("delay", 120*time.Millisecond, "Echo delay of the synthetic pair")
+	doubleTalk := flag.Bool("doubletalk", false, "Add a near-end talker to the synthetic pair")
+	filterLength := flag.Duration("filter", 64*time.Millisecond, "Echo canceller filter length")
+	maxDelay := flag.Duration("maxdelay", 500*time.Millisecond, "Largest delay searched")
+	flag.Parse()
+
+	var talker []float64
+	if *generate {
+		var far, near []int16
+		far, near, talker = synthesize(*duration, *delay, *doubleTalk)
+		writeWAV(*farPath, far)
+		writeWAV(*nearPath, near)
+		log.Printf("Wrote %s and %s (%v, echo delay %v, double talk %v)", *farPath, *nearPath, *duration, *delay, *doubleTalk)
+	}
+
+	far := readWAV(*farPath)
+	near := readWAV(*nearPath)
+	if len(far) < len(near) {
+		far = append(far, make([]int16, len(near)-len(far))...)
+	}
+
+	canceller := aec.NewCanceller(aec.Config{
+		SampleRate:   sampleRate,
+		FilterLength: *filterLength,
+		MaxDelay:     *maxDelay,
+	})
+
+	// Feed the far end in playback-callback blocks ahead of each
+	// microphone chunk, as the player and recorder do
+	out := make([]int16, 0, len(near))
+	pushed := 0
+	start := time.Now()
+	for pos := 0; pos+chunkSamples <= len(near); pos += chunkSamples {
+		for ; pushed < pos+chunkSamples && pushed < len(far); pushed += playbackBlock {
+			canceller.PushFarEnd(far[pushed:min(pushed+playbackBlock, len(far))])
+		}
+		result := canceller.Process(near[pos : pos+chunkSamples])
+		out = append(out, result...)
+
+		if (pos/chunkSamples)%5 == 4 {
+			stats := canceller.Stats()
+			fmt.Printf("%5.1fs  in %6.1f dB  out %6.1f dB  delay %5.1f ms  ERLE %5.1f dB  converged %-5v  double talk %v\n",
+				float64(pos+chunkSamples)/sampleRate,
+				level(near[pos:pos+chunkSamples]), level(result),
+				stats.DelayMs, stats.ERLE, stats.Converged, stats.DoubleTalk)
+		}
+	}
+	elapsed := time.Since(start)
+
+	writeWAV(*outPath, out)
+	fmt.Printf("Processed %.1fs of audio in %v\n", float64(len(out))/sampleRate, elapsed.Round(time.Millisecond))
+
+	if talker != nil {
+		report(near[:len(out)], out, talker[:len(out)])
+	}
+}
+
+// report compares echo removal during playback only and the near-end
+// talker's survival during double talk
+func report(near, out []int16, talker []float64) {
+	var echoIn, echoOut, dtIn, dtOut float64
+	for i := len(near) / 2; i < len(near); i++ { // After convergence
+		residual := float64(out[i]) - talker[i]
+		if talker[i] == 0 {
+			echoIn += float64(near[i]) * float64(near[i])
+			echoOut += float64(out[i]) * float64(out[i])
+		} else {
+			dtIn += talker[i] * talker[i]
+			dtOut += residual * residual
+		}
+	}
+	fmt.Printf("Echo reduction (second half, playback only): %.1f dB\n", 10*math.Log10((echoIn+1)/(echoOut+1)))
+	if dtIn > 0 {
+		fmt.Printf("Near-end talker to residual ratio during double talk: %.1f dB\n", 10*math.Log10((dtIn+1)/(dtOut+1)))
+	}
+}
+
+// synthesize creates speech-like playback, the microphone signal holding
+// its echo, and the near-end talker added to the microphone signal
+func synthesize(duration, delay time.Duration, doubleTalk bool) ([]int16, []int16, []float64) {
+	rng := rand.New(rand.NewSource(1))
+	n := int(duration.Seconds() * sampleRate)
+
+	far := voice(rng, n, 140, 0)
+	talker := make([]float64, n)
+	if doubleTalk {
+		// The user talks over the last quarter of the playback
+		voiced := voice(rng, n, 220, 0.3)
+		for i := 3 * n / 4; i < n; i++ {
+			talker[i] = float64(voiced[i])
+		}
+	}
+
+	// Room echo path: direct sound after the delay, decaying reflections
+	path := make([]float64, sampleRate*40/1000)
+	path[0] = 0.6
+	for i := 1; i < len(path); i++ {
+		path[i] = rng.NormFloat64() * 0.08 * math.Exp(-float64(i)/float64(len(path)/4))
+	}
+
+	offset := int(delay.Seconds() * sampleRate)
+	near := make([]int16, n)
+	for i := range near {
+		var echo float64
+		for k, h := range path {
+			if j := i - offset - k; j >= 0 {
+				echo += h * float64(far[j])
+			}
+		}
+		near[i] = clip(echo + talker[i] + rng.NormFloat64()*20)
+	}
+	return far, near, talker
+}
+
+// voice creates a pulse-train "speaker" with a wandering pitch, formant-like
+// resonances and syllable envelopes
+func voice(rng *rand.Rand, n int, pitch, seed float64) []int16 {
+	out := make([]int16, n)
+	var phase, r1, r2, s1, s2 float64
+	for i := range out {
+		t := float64(i) / sampleRate
+		f0 := pitch * (1 + 0.15*math.Sin(2*math.Pi*0.7*t+seed))
+		phase += f0 / sampleRate
+		excitation := rng.NormFloat64() * 0.1
+		if phase >= 1 {
+			phase--
+			excitation += 1
+		}
+
+		// Two resonators around 700 Hz and 1800 Hz
+		y1 := excitation + 1.8*math.Cos(2*math.Pi*700/sampleRate)*r1*0.95 - 0.9025*r2
+		r2, r1 = r1, y1
+		y2 := excitation + 1.8*math.Cos(2*math.Pi*1800/sampleRate)*s1*0.9 - 0.81*s2
+		s2, s1 = s1, y2
+
+		envelope := math.Max(0, math.Sin(2*math.Pi*2.3*t+seed)+0.6*math.Sin(2*math.Pi*0.9*t+2*seed))
+		out[i] = clip((y1 + y2) * 600 * envelope)
+	}
+	return out
+}
+
+func level(samples []int16) float64 {
+	return 20 * math.Log10(utils.CalculateRMS(samples)+1)
+}
+
+func clip(v float64) int16 {
+	return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, v)))
+}
+
+func readWAV(path string) []int16 {
+	samples, rate, err := utils.ReadWAV(path)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if rate != sampleRate {
+		samples = utils.ResampleAudio(samples, rate, sampleRate)
+	}
+	return samples
+}
+
+func writeWAV(path string, samples []int16) {
+	if err := os.WriteFile(path, utils.ConvertSamplesToWAV(samples, sampleRate, 1, 2), 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"websocket_client_chat/internal/auth"
+	"websocket_client_chat/internal/config"
+	"websocket_client_chat/internal/control"
+)
+
+// === Implementation of control.APIHandler interface ===
+
+// Wake starts a session from a remote wake source
+func (app *App) Wake(source string) error {
+	if !app.sessionMode() || (app.controlMode == "gpio" && app.config.Gpio.Mode == config.GpioModePushToTalk) {
+		return fmt.Errorf("%w: wake needs gpio (wake) or wakeword mode", control.ErrUnavailable)
+	}
+	if !app.wsClient.IsConnected() {
+		return fmt.Errorf("%w: WebSocket not connected", control.ErrUnavailable)
+	}
+
+	// The wake path may block on the cancel cooldown
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.wake(source, 0)
+	}()
+	return nil
+}
+
+// SetOutputVolume sets the playback volume (0-1)
+func (app *App) SetOutputVolume(volume float64) {
+	app.player.SetOutputVolume(volume)
+}
+
+// OutputVolume returns the playback volume
+func (app *App) OutputVolume() float64 {
+	return app.player.OutputVolume()
+}
+
+// StatusReport returns the status for the HTTP API
+func (app *App) StatusReport() any {
+	return app.Status()
+}
+
+// ConfigReport returns the configuration in effect, without credentials
+func (app *App) ConfigReport() any {
+	// Device is written by UpdateDevice under deviceMutex
+	app.deviceMutex.RLock()
+	cfg := *app.config
+	app.deviceMutex.RUnlock()
+	if cfg.WebSocket.Proxy.URL != "" {
+		cfg.WebSocket.Proxy.URL = auth.RedactURL(cfg.WebSocket.Proxy.URL)
+	}
+	return cfg
+}
+
+// UpdateDevice changes the device settings. They are sent with the config
+// update of the next session, which the server may reject.
+func (app *App) UpdateDevice(update control.DeviceUpdate) error {
+	if update.VoiceID != nil && *update.VoiceID == "" {
+		return fmt.Errorf("voiceId must not be empty")
+	}
+
+	app.deviceMutex.Lock()
+	if update.VoiceID != nil {
+		app.config.Device.VoiceID = *update.VoiceID
+	}
+	if update.SpeechRate != nil {
+		app.config.Device.SpeechRate = *update.SpeechRate
+	}
+	device := app.config.Device
+	app.deviceMutex.Unlock()
+
+	log.Printf("Device settings updated: voice %s, speech rate %d (applied from the next session)", device.VoiceID, device.SpeechRate)
+	return nil
+}
+
+// deviceConfig returns a copy of the device settings
+func (app *App) deviceConfig() config.DeviceConfig {
+	app.deviceMutex.RLock()
+	defer app.deviceMutex.RUnlock()
+	return app.config.Device
+}
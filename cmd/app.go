@@ -2,46 +2,30 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"websocket_client_chat/internal/audio"
+	"websocket_client_chat/internal/auth"
+	"websocket_client_chat/internal/bargein"
 	"websocket_client_chat/internal/config"
 	"websocket_client_chat/internal/control"
+	"websocket_client_chat/internal/conversation"
+	"websocket_client_chat/internal/events"
+	"websocket_client_chat/internal/session"
+	"websocket_client_chat/internal/wakeword"
 	"websocket_client_chat/internal/websocket"
+	"websocket_client_chat/pkg/aec"
 	"websocket_client_chat/pkg/utils"
 )
 
-// AppState represents the program state in GPIO mode
-type AppState int32
-
-const (
-	StateSleeping        AppState = 0 // Default: buffering audio to circular wake buffer
-	StateWaitingResponse AppState = 1 // Waiting for wake response audio to finish playing
-	StateActive          AppState = 2 // Actively streaming audio to backend (after wake response)
-)
-
-// String returns a human-readable name for the AppState
-func (s AppState) String() string {
-	switch s {
-	case StateSleeping:
-		return "SLEEPING"
-	case StateWaitingResponse:
-		return "WAITING_RESPONSE"
-	case StateActive:
-		return "ACTIVE"
-	default:
-		return fmt.Sprintf("UNKNOWN(%d)", int32(s))
-	}
-}
-
 // App is the main application structure
 type App struct {
-	config *config.Config
+	config      *config.Config
+	deviceMutex sync.RWMutex // Protects config.Device, which the HTTP API can change
 
 	// Components
 	recorder     *audio.Recorder
@@ -50,26 +34,42 @@ type App struct {
 	fileMonitor  *control.FileMonitor
 	stdinMonitor *control.StdinMonitor
 	gpioMonitor  *control.GpioMonitor
+	httpServer   *control.HTTPServer
+	socket       *control.SocketMonitor
+	spotter      wakeword.Spotter // Keyword spotter in wakeword mode
+	conversation *conversation.Store
+	chats        *chatFilter
+	echo         *aec.Canceller    // Software echo canceller, nil when disabled
+	bargeIn      *bargein.Detector // Local barge-in detector, nil when disabled
+	events       *events.Bus       // Application events for outputs other than the log
 
 	// State management
-	updateCh    chan struct{} // Signals config update response received
-	enableDebug bool          // Debug mode switch
-	controlMode string        // Control mode: "stdin", "file", or "gpio"
+	enableDebug bool   // Debug mode switch
+	controlMode string // Control mode: "stdin", "file", "http", "gpio", or "wakeword"
+
+	// Replay of a capture file instead of a live connection
+	replayPath  string
+	replaySpeed float64
 
-	// GPIO mode state
-	state              atomic.Int32 // Current state (StateSleeping, StateWaitingResponse, or StateActive)
-	currentRequestID   string       // Active session request ID
+	// GPIO and wakeword mode state
+	session            *session.Machine // SLEEPING / WAITING_RESPONSE / ACTIVE state machine
+	currentRequestID   string           // Active session request ID
 	requestIDMutex     sync.RWMutex
 	wakeBuffer         []int16    // Circular buffer for wake audio
 	wakeBufferMutex    sync.Mutex // Protects wakeBuffer
 	wakeBufferMaxSize  int        // Max samples in wake buffer
 	silenceBuffer      []int16    // Buffer for silence detection
-	silenceBufferMutex sync.Mutex // Protects silenceBuffer
+	silenceBufferMutex sync.Mutex // Protects silenceBuffer and vad
+	vad                *utils.VAD // Voice activity detector ending ACTIVE turns, nil with the RMS check
 	silenceBufferSize  int        // Max samples in silence buffer
+	pttReady           bool       // Push-to-talk session configured; until then audio is held in wakeBuffer (protected by wakeBufferMutex)
+	pttStarting        bool       // Push-to-talk session being set up (protected by wakeBufferMutex)
+	pttReleased        bool       // Button released while starting; the turn ends once set up (protected by wakeBufferMutex)
 
-	// WaitingResponse timeout tracking
-	waitingResponseSince time.Time    // When we entered StateWaitingResponse after silence
-	waitingResponseMutex sync.RWMutex // Protects waitingResponseSince
+	// Ambient noise measured while SLEEPING
+	noiseFloor  *utils.NoiseFloor
+	noiseLogged float64 // Last logged estimate
+	noiseMutex  sync.Mutex
 
 	// Context control
 	ctx    context.Context
@@ -84,15 +84,15 @@ func NewApp(controlMode string) *App {
 
 	app := &App{
 		config:      cfg,
+		events:      events.NewBus(),
 		ctx:         ctx,
 		cancel:      cancel,
-		updateCh:    make(chan struct{}, 1),
 		enableDebug: cfg.EnableDebug,
 		controlMode: controlMode,
 	}
 
-	// Initialize GPIO mode buffers
-	if controlMode == "gpio" {
+	// Initialize session mode buffers
+	if app.sessionMode() {
 		// Wake buffer: bufferDuration seconds of audio at output sample rate
 		app.wakeBufferMaxSize = int(cfg.Wake.BufferDuration.Seconds()) * cfg.Audio.SampleRate
 		app.wakeBuffer = make([]int16, 0, app.wakeBufferMaxSize)
@@ -100,26 +100,92 @@ func NewApp(controlMode string) *App {
 		// Silence buffer: configured seconds of audio at output sample rate
 		app.silenceBufferSize = cfg.Wake.SilenceBufferSeconds * cfg.Audio.SampleRate
 		app.silenceBuffer = make([]int16, 0, app.silenceBufferSize)
+
+		chunks := int(cfg.Wake.NoiseWindow / cfg.Audio.ChunkDuration)
+		app.noiseFloor = utils.NewNoiseFloor(chunks, chunks/5)
+
+		if cfg.Wake.SilenceDetector == config.SilenceDetectorVAD {
+			app.vad = utils.NewVAD(cfg.Audio.SampleRate, cfg.Wake.VADAggressiveness)
+		}
 	}
 
 	// Initialize components
 	app.recorder = audio.NewRecorder(&cfg.Audio, app, cfg.EnableDebug)
 	app.player = audio.NewPlayer(ctx, &cfg.Audio, cfg.EnableDebug)
+	app.player.SetEventBus(app.events)
+	if cfg.Audio.EchoCancellation {
+		app.echo = aec.NewCanceller(aec.Config{
+			SampleRate:   cfg.Audio.SampleRate,
+			FilterLength: cfg.Audio.EchoFilterLength,
+			MaxDelay:     cfg.Audio.EchoMaxDelay,
+		})
+		app.recorder.SetEchoCanceller(app.echo)
+		app.player.SetEchoReference(app.echo)
+		log.Printf("Echo cancellation enabled (filter %v, max delay %v)",
+			cfg.Audio.EchoFilterLength, cfg.Audio.EchoMaxDelay)
+	}
+	if cfg.BargeIn.Enabled {
+		// Without echo cancellation or a fixed level above the echo, the
+		// device's own voice would interrupt every response
+		if !cfg.Audio.EchoCancellation && cfg.BargeIn.MinLevelRMS <= 0 {
+			log.Println("Warning: barge_in needs echo_cancellation or barge_in_min_rms, local barge-in disabled")
+			cfg.BargeIn.Enabled = false
+		} else {
+			app.bargeIn = bargein.NewDetector(&cfg.BargeIn, cfg.Audio.SampleRate, session.SystemClock{})
+		}
+	}
 	app.wsClient = websocket.NewClient(ctx, &cfg.WebSocket, app, cfg.EnableDebug)
+	app.wsClient.SetEventBus(app.events)
+	app.conversation = conversation.NewStore(&cfg.Conversation, cfg.EnableDebug)
+	app.chats = newChatFilter(cfg.EnableDebug)
+	app.session = session.NewMachine(session.SystemClock{}, session.DefaultResponseTimeout, app, cfg.EnableDebug)
+	app.session.OnTransition(app.logTransition)
+	if provider := auth.NewTokenProvider(&cfg.WebSocket, cfg.Device.SerialNumber); provider != nil {
+		app.wsClient.SetTokenProvider(provider)
+	} else {
+		log.Println("Warning: no access token configured, connecting without authentication")
+	}
 
 	// Select control mode based on command-line argument
 	switch controlMode {
 	case "gpio":
 		app.gpioMonitor = control.NewGpioMonitor(ctx, &cfg.Gpio, app)
+		app.gpioMonitor.SetEventBus(app.events)
+	case "wakeword":
+		// The spotter is loaded in Start so template errors are reported
 	case "stdin":
 		app.stdinMonitor = control.NewStdinMonitor(ctx, app)
+		app.stdinMonitor.SetEventBus(app.events)
+	case "http":
+		// Controlled through the HTTP API only
 	default:
 		app.fileMonitor = control.NewFileMonitor(ctx, &cfg.Control, app)
+		app.fileMonitor.SetEventBus(app.events)
+	}
+	if cfg.HTTP.Enabled || controlMode == "http" {
+		app.httpServer = control.NewHTTPServer(ctx, &cfg.HTTP, app)
+		app.httpServer.SetEventBus(app.events)
+	}
+	if cfg.Control.SocketPath != "" {
+		app.socket = control.NewSocketMonitor(ctx, &cfg.Control, app)
+		app.socket.SetEventBus(app.events)
 	}
 
 	return app
 }
 
+// Events returns the application's event bus
+func (app *App) Events() *events.Bus {
+	return app.events
+}
+
+// SetReplay makes Start replay the inbound messages of a capture file
+// instead of connecting to the server. Must be called before Start.
+func (app *App) SetReplay(path string, speed float64) {
+	app.replayPath = path
+	app.replaySpeed = speed
+}
+
 // Start starts the application
 func (app *App) Start() error {
 	// Initialize PortAudio
@@ -127,33 +193,57 @@ func (app *App) Start() error {
 		return err
 	}
 
-	// Start WebSocket client
-	if err := app.wsClient.Start(); err != nil {
+	// Start WebSocket client, or feed it a capture file
+	if app.replayPath != "" {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			if err := app.wsClient.Replay(app.ctx, app.replayPath, app.replaySpeed); err != nil && app.ctx.Err() == nil {
+				log.Printf("Replay failed: %v", err)
+			}
+		}()
+	} else if err := app.wsClient.Start(); err != nil {
 		return err
 	}
 
 	// Start the corresponding control mode
 	switch app.controlMode {
-	case "gpio":
-		// GPIO mode: start continuous recording immediately, then GPIO monitor
-		if err := app.gpioMonitor.Start(); err != nil {
+	case "gpio", "wakeword":
+		// Session modes: start the wake source, then continuous recording
+		if app.controlMode == "wakeword" {
+			spotter, err := wakeword.NewSpotter(&app.config.WakeWord, app.config.Audio.SampleRate, app.enableDebug)
+			if err != nil {
+				return fmt.Errorf("failed to load wake word spotter: %w", err)
+			}
+			app.spotter = spotter
+		} else if err := app.gpioMonitor.Start(); err != nil {
 			return fmt.Errorf("failed to start GPIO monitor: %w", err)
 		}
 
-		// Start continuous recording (state defaults to Sleeping)
-		app.state.Store(int32(StateSleeping))
+		// Start continuous recording (the session starts in SLEEPING)
 		if err := app.recorder.StartRecording("gpio-continuous"); err != nil {
 			return fmt.Errorf("failed to start continuous recording: %w", err)
 		}
 
+		if app.controlMode == "gpio" && app.config.Gpio.Mode == config.GpioModePushToTalk {
+			log.Println("Voice intercom system started successfully (GPIO push-to-talk mode)")
+			log.Println("Hold the button to talk, release it to send")
+			break
+		}
+
 		// Start silence detection loop
 		app.wg.Add(1)
 		go app.silenceCheckLoop()
 
-		log.Println("Voice intercom system started successfully (GPIO mode)")
-		log.Printf("========== [STATE](%s) Buffering audio to wake buffer ==========", AppState(app.state.Load()))
-		log.Printf("Wake buffer: %.1f seconds, Silence check: every %v",
-			app.config.Wake.BufferDuration.Seconds(), app.config.Wake.SilenceCheckInterval)
+		log.Printf("Voice intercom system started successfully (%s mode)", app.controlMode)
+		log.Printf("========== [STATE](%s) Buffering audio to wake buffer ==========", app.session.State())
+		if app.vad != nil {
+			log.Printf("Wake buffer: %.1f seconds, Silence check: VAD (aggressiveness %d, end of speech after %v)",
+				app.config.Wake.BufferDuration.Seconds(), app.config.Wake.VADAggressiveness, app.config.Wake.EndOfSpeechSilence)
+		} else {
+			log.Printf("Wake buffer: %.1f seconds, Silence check: every %v",
+				app.config.Wake.BufferDuration.Seconds(), app.config.Wake.SilenceCheckInterval)
+		}
 
 	case "stdin":
 		if err := app.stdinMonitor.Start(); err != nil {
@@ -163,8 +253,15 @@ func (app *App) Start() error {
 		log.Println("Enter commands:")
 		log.Println("  1 or start - start recording")
 		log.Println("  2 or stop  - stop recording and send")
+		log.Println("  4 or cancel - cancel the current answer")
+		log.Println("  5 or clear  - clear conversation context")
+		log.Println("  6 or new    - start a new conversation")
+		log.Println("  7 or status - log the application status")
 		log.Println("  q or quit  - exit program")
 
+	case "http":
+		log.Println("Voice intercom system started successfully (HTTP control mode)")
+
 	default:
 		if err := app.fileMonitor.Start(); err != nil {
 			return err
@@ -174,6 +271,21 @@ func (app *App) Start() error {
 		log.Println("Write to /tmp/chat-control:")
 		log.Println("  1 - start recording")
 		log.Println("  2 - stop recording and send")
+		log.Println("  4 - cancel the current answer")
+		log.Println("  5 - clear conversation context")
+		log.Println("  6 - start a new conversation")
+		log.Println("  7 - log the application status")
+	}
+
+	if app.httpServer != nil {
+		if err := app.httpServer.Start(); err != nil {
+			return fmt.Errorf("failed to start HTTP API: %w", err)
+		}
+	}
+	if app.socket != nil {
+		if err := app.socket.Start(); err != nil {
+			return fmt.Errorf("failed to start control socket: %w", err)
+		}
 	}
 
 	return nil
@@ -202,9 +314,28 @@ func (app *App) Stop() error {
 		}
 	}
 
+	if app.httpServer != nil {
+		if err := app.httpServer.Stop(); err != nil {
+			log.Printf("Failed to stop HTTP API: %v", err)
+		}
+	}
+
+	if app.socket != nil {
+		if err := app.socket.Stop(); err != nil {
+			log.Printf("Failed to stop control socket: %v", err)
+		}
+	}
+
 	if err := app.wsClient.Stop(); err != nil {
 		log.Printf("Failed to stop WebSocket client: %v", err)
 	}
+	app.conversation.Flush()
+
+	if stats := app.StaleOutputStats(); stats.CancelledChats > 0 {
+		log.Printf("Stale output dropped: %d audio message(s) (%d bytes), %d text message(s), %d other, %d cancelled chat(s)",
+			stats.DroppedAudioMessages, stats.DroppedAudioBytes, stats.DroppedTextMessages,
+			stats.DroppedOtherMessages, stats.CancelledChats)
+	}
 
 	if err := app.player.Stop(); err != nil {
 		log.Printf("Failed to stop audio player: %v", err)
@@ -221,6 +352,11 @@ func (app *App) Stop() error {
 	return nil
 }
 
+// StaleOutputStats returns how much output of cancelled or superseded chats was dropped
+func (app *App) StaleOutputStats() StaleOutputStats {
+	return app.chats.snapshot()
+}
+
 // Wait waits for the application to finish
 func (app *App) Wait() {
 	<-app.ctx.Done()
@@ -230,70 +366,191 @@ func (app *App) Wait() {
 
 // HandleCommand handles control commands (for stdin/file modes)
 func (app *App) HandleCommand(cmd control.Command) {
-	switch cmd {
-	case control.CmdStartRecording:
-		if !app.recorder.IsRecording() {
-			requestID := utils.GenerateRequestID(app.config.Device.SerialNumber)
+	if err := app.ExecuteCommand(cmd); err != nil {
+		log.Printf("Command %s ignored: %v", cmd, err)
+	}
+}
 
-			// Send config update request and wait for response
-			app.wg.Add(1)
-			go func() {
-				defer app.wg.Done()
-				app.sendUpdateConfigAndWait(requestID)
+// ExecuteCommand runs a control command. Commands that wait for the server
+// continue in the background; the error only reports a refused command.
+func (app *App) ExecuteCommand(cmd control.Command) error {
+	return app.runCommand(cmd, false)
+}
 
-				// Start recording after config update succeeds
-				if err := app.recorder.StartRecording(requestID); err != nil {
-					log.Printf("Failed to start recording: %v", err)
-				}
-			}()
-		} else {
-			log.Println("System busy, ignoring start recording command")
+// ExecuteCommandWait runs a control command and waits for its outcome, such
+// as the server's acknowledgment; the error also reports a failed command.
+func (app *App) ExecuteCommandWait(cmd control.Command) error {
+	return app.runCommand(cmd, true)
+}
+
+// runCommand runs a control command, waiting for its outcome if wait is set
+func (app *App) runCommand(cmd control.Command, wait bool) error {
+	switch cmd {
+	case control.CmdStartRecording, control.CmdStopRecording, control.CmdTestRecording:
+		// The recorder runs continuously in session modes
+		if app.sessionMode() {
+			return fmt.Errorf("%w: recording is controlled by the %s wake source", control.ErrUnavailable, app.controlMode)
 		}
+	}
 
-	case control.CmdStopRecording:
+	switch cmd {
+	case control.CmdStartRecording:
 		if app.recorder.IsRecording() {
-			if err := app.recorder.StopRecording(); err != nil {
-				log.Printf("Failed to stop recording: %v", err)
+			return fmt.Errorf("%w: system busy, already recording", control.ErrUnavailable)
+		}
+		requestID := utils.GenerateRequestID(app.config.Device.SerialNumber)
+		app.requestIDMutex.Lock()
+		app.currentRequestID = requestID
+		app.requestIDMutex.Unlock()
+
+		// Send config update request and wait for response
+		return app.background(wait, func() error {
+			if err := app.sendUpdateConfigAndWait(requestID); err != nil {
+				return err
 			}
-		} else {
-			log.Println("Not in recording state, ignoring stop command")
+
+			// Start recording after config update succeeds
+			if err := app.recorder.StartRecording(requestID); err != nil {
+				log.Printf("Failed to start recording: %v", err)
+				return fmt.Errorf("failed to start recording: %w", err)
+			}
+			return nil
+		})
+
+	case control.CmdStopRecording:
+		if !app.recorder.IsRecording() {
+			return fmt.Errorf("%w: not recording", control.ErrUnavailable)
+		}
+		if err := app.recorder.StopRecording(); err != nil {
+			return fmt.Errorf("failed to stop recording: %w", err)
 		}
 
 	case control.CmdTestRecording:
 		if app.recorder.IsRecording() {
-			log.Println("Currently recording, cannot start test recording")
-			return
+			return fmt.Errorf("%w: currently recording, cannot start test recording", control.ErrUnavailable)
 		}
 
 		// Execute test recording asynchronously
-		app.wg.Add(1)
-		go func() {
-			defer app.wg.Done()
-
+		return app.background(wait, func() error {
 			// Generate filename with timestamp
 			filename := fmt.Sprintf("test_recording_%s.wav", time.Now().Format("20060102_150405"))
 
 			// Record for 5 seconds
 			if err := app.recorder.TestRecording(5, filename); err != nil {
 				log.Printf("Test recording failed: %v", err)
+				return fmt.Errorf("test recording failed: %w", err)
 			}
-		}()
+			return nil
+		})
+
+	case control.CmdCancelOutput:
+		return app.background(wait, app.cancelOutput)
+
+	case control.CmdClearContext:
+		return app.background(wait, app.clearContext)
+
+	case control.CmdNewConversation:
+		return app.background(wait, func() error {
+			// A failed cancel does not keep the context from being cleared
+			cancelErr := app.cancelOutput()
+			app.chats.cancelConversation()
+			if err := app.clearContext(); err != nil {
+				return err
+			}
+			app.conversation.Clear()
+			log.Println("New conversation will start with the next request")
+			return cancelErr
+		})
+
+	case control.CmdStatus:
+		app.LogStatus()
 
 	case control.CmdQuit:
 		log.Println("Quit command received, shutting down...")
 		app.cancel()
 	}
+	return nil
+}
+
+// background runs fn in the background, or runs it and returns its error
+// if wait is set
+func (app *App) background(wait bool, fn func() error) error {
+	if wait {
+		return fn()
+	}
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		_ = fn()
+	}()
+	return nil
+}
+
+// cancelOutput stops local playback and asks the server to stop the current
+// answer, waiting for its acknowledgment. In GPIO mode the session ends.
+func (app *App) cancelOutput() error {
+	app.chats.cancelActive()
+	if app.player.IsPlaying() {
+		app.player.StopPlayback()
+	}
+	app.player.ClearBuffer()
+	if app.sessionMode() && app.session.State() != session.StateSleeping {
+		app.returnToSleeping("cancelled by command")
+	}
+
+	app.requestIDMutex.RLock()
+	reqID := app.currentRequestID
+	app.requestIDMutex.RUnlock()
+	if reqID == "" {
+		log.Println("No active request, nothing to cancel on the server")
+		return nil
+	}
+
+	resp, err := app.wsClient.CancelOutput(app.ctx, reqID)
+	if err != nil {
+		if app.ctx.Err() == nil {
+			log.Printf("Cancel output failed: %v", err)
+		}
+		return fmt.Errorf("cancel output failed: %w", err)
+	}
+	log.Printf("Cancel output acknowledged by server (type: %s)", resp.Data.CancelType)
+	return nil
+}
+
+// clearContext asks the server to clear the conversation context and waits
+// for its acknowledgment
+func (app *App) clearContext() error {
+	requestID := utils.GenerateRequestID(app.config.Device.SerialNumber)
+	if err := app.wsClient.ClearContext(app.ctx, requestID); err != nil {
+		if app.ctx.Err() == nil {
+			log.Printf("Clear context failed: %v", err)
+		}
+		return fmt.Errorf("clear context failed: %w", err)
+	}
+	log.Println("Clear context acknowledged by server")
+	return nil
 }
 
 // === Implementation of control.GpioHandler interface ===
 
+// OnGpioCommand runs the command of a GPIO command button
+func (app *App) OnGpioCommand(cmd control.Command) {
+	app.HandleCommand(cmd)
+}
+
 // OnGpioWake is called when a falling edge is detected on the GPIO pin
 func (app *App) OnGpioWake() {
-	currentState := AppState(app.state.Load())
+	app.wake("GPIO", 0)
+}
+
+// wake starts a new session from a wake source. The last preRoll samples
+// of the wake buffer (all of it if preRoll is 0) are sent as wake audio.
+func (app *App) wake(source string, preRoll int) {
+	currentState := app.session.State()
 
 	// Check WebSocket connection
 	if !app.wsClient.IsConnected() {
-		log.Println("GPIO wake ignored: WebSocket not connected")
+		log.Printf("%s wake ignored: WebSocket not connected", source)
 		return
 	}
 
@@ -302,37 +559,50 @@ func (app *App) OnGpioWake() {
 	// WAITING_RESPONSE the wake buffer is continuously maintained, so it
 	// contains recent audio that includes the wake phrase.
 	app.wakeBufferMutex.Lock()
-	wakeAudio := make([]int16, len(app.wakeBuffer))
-	copy(wakeAudio, app.wakeBuffer)
+	wakeAudio := app.wakeBuffer
+	if preRoll > 0 && len(wakeAudio) > preRoll {
+		wakeAudio = wakeAudio[len(wakeAudio)-preRoll:]
+	}
+	wakeAudio = append([]int16(nil), wakeAudio...)
 	app.wakeBufferMutex.Unlock()
 
 	// If not in sleeping state, interrupt current session first
-	if currentState != StateSleeping {
-		log.Printf("GPIO wake during state %s, interrupting current session", currentState)
+	if currentState != session.StateSleeping {
+		log.Printf("%s wake during state %s, interrupting current session", source, currentState)
 		app.interruptCurrentSession()
 	}
 
-	log.Printf("========== [STATE](%s) GPIO wake triggered, transitioning to WAITING_RESPONSE ==========", AppState(app.state.Load()))
+	log.Printf("========== [STATE](%s) %s wake triggered, transitioning to WAITING_RESPONSE ==========", app.session.State(), source)
 
 	// Generate a new request ID for this session
 	requestID := utils.GenerateRequestID(app.config.Device.SerialNumber)
 	app.requestIDMutex.Lock()
 	app.currentRequestID = requestID
 	app.requestIDMutex.Unlock()
+	app.events.Publish(events.TypeWake, events.SourceApp, events.Wake{Source: source, RequestID: requestID})
 
 	app.wg.Add(1)
 	go func() {
 		defer app.wg.Done()
 
 		// Send config update and wait for acknowledgment
-		app.sendUpdateConfigAndWait(requestID)
+		if err := app.sendUpdateConfigAndWait(requestID); err != nil {
+			if app.ctx.Err() == nil {
+				app.returnToSleeping("config update failed")
+			}
+			return
+		}
 
 		// Only send wake audio if the buffer has data. An empty buffer means
 		// something unexpected happened; skip the wake audio and let the
 		// backend start a fresh session via the normal audio stream path.
 		if len(wakeAudio) > 0 {
-			wavData := app.recorder.ConvertToWAV(wakeAudio)
-			if err := app.wsClient.SendWakeAudio(requestID, wavData); err != nil {
+			payload, err := app.recorder.EncodeAudio(wakeAudio)
+			if err != nil {
+				log.Printf("Failed to encode wake audio: %v", err)
+				return
+			}
+			if err := app.wsClient.SendWakeAudio(requestID, payload); err != nil {
 				log.Printf("Failed to send wake audio: %v", err)
 				return
 			}
@@ -346,30 +616,124 @@ func (app *App) OnGpioWake() {
 
 		// Switch to waiting response state (NOT active yet)
 		// Audio will be buffered but silence detection won't trigger until playback completes
-		app.state.Store(int32(StateWaitingResponse))
-		log.Printf("========== [STATE](%s) Waiting for wake response audio ==========", AppState(app.state.Load()))
+		_, _ = app.session.Fire(session.EventSessionStarted, "Waiting for wake response audio")
 	}()
 }
 
+// OnGpioPress is called when the push-to-talk button is pressed. A new
+// session starts and interrupts any playing response; audio is held in the
+// wake buffer until the server acknowledged the config update, then
+// streamed until the button is released. The setup runs in the background
+// so the GPIO monitor keeps reading edges.
+func (app *App) OnGpioPress() {
+	if !app.wsClient.IsConnected() {
+		log.Println("Push-to-talk ignored: WebSocket not connected")
+		return
+	}
+
+	app.wakeBufferMutex.Lock()
+	if app.pttStarting {
+		app.wakeBufferMutex.Unlock()
+		log.Println("Push-to-talk ignored: previous press still being set up")
+		return
+	}
+	app.pttStarting = true
+	app.pttReleased = false
+	app.pttReady = false
+	app.wakeBufferMutex.Unlock()
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.startPushToTalk()
+	}()
+}
+
+// startPushToTalk interrupts the current session, starts a new one and
+// waits for its config update. A release reported meanwhile ends the turn
+// once the session is set up.
+func (app *App) startPushToTalk() {
+	ready := false
+	defer func() {
+		app.wakeBufferMutex.Lock()
+		released := app.pttReleased
+		app.pttStarting = false
+		app.pttReleased = false
+		app.wakeBufferMutex.Unlock()
+		if ready && released {
+			app.OnGpioRelease()
+		}
+	}()
+
+	if app.player.IsPlaying() || app.session.State() != session.StateSleeping {
+		log.Println("Push-to-talk during a response, interrupting current session")
+		app.interruptCurrentSession()
+	}
+
+	requestID := utils.GenerateRequestID(app.config.Device.SerialNumber)
+	app.requestIDMutex.Lock()
+	app.currentRequestID = requestID
+	app.requestIDMutex.Unlock()
+	app.events.Publish(events.TypeWake, events.SourceApp, events.Wake{Source: "Push-to-talk", RequestID: requestID})
+
+	if _, err := app.session.Fire(session.EventTalkStarted, "Push-to-talk pressed, streaming audio"); err != nil {
+		return
+	}
+
+	if err := app.sendUpdateConfigAndWait(requestID); err != nil {
+		if app.ctx.Err() == nil {
+			app.returnToSleeping("config update failed")
+		}
+		return
+	}
+
+	// Send the audio captured while waiting, later chunks are streamed directly
+	app.wakeBufferMutex.Lock()
+	if app.session.State() == session.StateTalking {
+		if len(app.wakeBuffer) > 0 {
+			app.sendAudioStream(requestID, app.wakeBuffer)
+		}
+		app.wakeBuffer = app.wakeBuffer[:0]
+		app.pttReady = true
+		ready = true
+	}
+	app.wakeBufferMutex.Unlock()
+}
+
+// OnGpioRelease is called when the push-to-talk button is released. The
+// utterance ends with inputAudioComplete and the response plays while
+// SLEEPING. A release while the session is still being set up is held
+// until it is.
+func (app *App) OnGpioRelease() {
+	app.wakeBufferMutex.Lock()
+	if app.pttStarting {
+		app.pttReleased = true
+		app.wakeBufferMutex.Unlock()
+		return
+	}
+	app.wakeBufferMutex.Unlock()
+
+	_, _ = app.session.Fire(session.EventTalkEnded, "Push-to-talk released, waiting for the response")
+}
+
 // interruptCurrentSession stops playback and clears buffers for a new session.
 // It also enforces a cooldown after sending cancelOutput so the backend has time
 // to finish cleanup before the next session's messages arrive.
 func (app *App) interruptCurrentSession() {
-	// Stop audio playback immediately
+	// Drop whatever the interrupted chat still sends
+	app.chats.cancelActive()
+
+	// A push-to-talk response plays while SLEEPING, where no session event
+	// stops it, so playback is stopped here before the cooldown
 	if app.player.IsPlaying() {
-		log.Println("Interrupting audio playback")
 		app.player.StopPlayback()
 	}
+	app.player.ClearBuffer()
 
-	// Clear silence buffer
-	app.silenceBufferMutex.Lock()
-	app.silenceBuffer = app.silenceBuffer[:0]
-	app.silenceBufferMutex.Unlock()
-
-	// Clear wake buffer to start fresh
-	app.wakeBufferMutex.Lock()
-	app.wakeBuffer = app.wakeBuffer[:0]
-	app.wakeBufferMutex.Unlock()
+	// Clear the silence and wake buffers to start fresh
+	if app.session.State() != session.StateSleeping {
+		_, _ = app.session.Fire(session.EventWake, "Wake, current session interrupted")
+	}
 
 	// Send cancel output to backend to stop any ongoing processing
 	app.requestIDMutex.RLock()
@@ -388,53 +752,64 @@ func (app *App) interruptCurrentSession() {
 			// session's ASR connection can be torn down by the backend's
 			// still-in-progress cancel cleanup (the "abort all" path).
 			if app.config.Wake.CancelCooldown > 0 {
-				time.Sleep(app.config.Wake.CancelCooldown)
+				app.session.Clock().Sleep(app.config.Wake.CancelCooldown)
 			}
 		}
 	}
 }
 
+// sessionMode reports whether sessions are driven by the SLEEPING /
+// WAITING_RESPONSE / ACTIVE state machine (gpio and wakeword modes)
+func (app *App) sessionMode() bool {
+	return app.controlMode == "gpio" || app.controlMode == "wakeword"
+}
+
 // === Implementation of audio.Handler interface ===
 
 // OnAudioChunk handles audio chunks from the recorder
 func (app *App) OnAudioChunk(requestID string, samples []int16, isLast bool) {
-	if app.controlMode == "gpio" {
+	if app.bargeIn != nil {
+		app.checkBargeIn(samples)
+	}
+
+	if app.sessionMode() {
 		app.handleGpioAudioChunk(samples)
+		if app.spotter != nil {
+			app.spotWakeWord(samples)
+		}
 		return
 	}
 
-	// Original stdin/file mode behavior
-	wavData := app.recorder.ConvertToWAV(samples)
-
-	app.wg.Add(1)
-	go func() {
-		defer app.wg.Done()
+	// Original stdin/file mode behavior. Sends only queue the chunk; the
+	// WebSocket client writes them in order on its own goroutine.
+	payload, err := app.recorder.EncodeAudio(samples)
+	if err != nil {
+		log.Printf("Failed to encode audio data: %v", err)
+		return
+	}
 
-		var err error
-		if isLast {
-			err = app.wsClient.SendAudioComplete(requestID, wavData)
-			if err == nil && app.enableDebug {
-				log.Printf("Sent completion request (including last %d bytes of WAV audio)", len(wavData))
-			}
-		} else {
-			err = app.wsClient.SendAudioStream(requestID, wavData)
+	if isLast {
+		err = app.wsClient.SendAudioComplete(requestID, payload)
+		if err == nil && app.enableDebug {
+			log.Printf("Queued completion request (including last %d bytes of audio)", len(payload))
 		}
+	} else {
+		err = app.wsClient.SendAudioStream(requestID, payload)
+	}
 
-		if err != nil {
-			log.Printf("Failed to send audio data: %v", err)
-		}
-	}()
+	if err != nil {
+		log.Printf("Failed to send audio data: %v", err)
+	}
 }
 
-// handleGpioAudioChunk routes audio based on the current GPIO mode state
+// handleGpioAudioChunk routes audio based on the current session state
 func (app *App) handleGpioAudioChunk(samples []int16) {
-	state := AppState(app.state.Load())
-
-	switch state {
-	case StateSleeping:
+	switch app.session.State() {
+	case session.StateSleeping:
 		app.appendToWakeBuffer(samples)
+		app.trackNoiseFloor(samples)
 
-	case StateWaitingResponse:
+	case session.StateWaitingResponse:
 		// While waiting for response, send audio to backend for interrupt detection
 		// AND also buffer to wake buffer for potential GPIO wake
 		app.appendToWakeBuffer(samples)
@@ -443,17 +818,25 @@ func (app *App) handleGpioAudioChunk(samples []int16) {
 		reqID := app.currentRequestID
 		app.requestIDMutex.RUnlock()
 
-		wavData := app.recorder.ConvertToWAV(samples)
+		app.sendAudioStream(reqID, samples)
 
-		app.wg.Add(1)
-		go func() {
-			defer app.wg.Done()
-			if err := app.wsClient.SendAudioStream(reqID, wavData); err != nil {
-				log.Printf("Failed to send audio stream: %v", err)
-			}
-		}()
+	case session.StateTalking:
+		// Hold audio until the push-to-talk session is configured
+		app.wakeBufferMutex.Lock()
+		if !app.pttReady {
+			app.appendToWakeBufferLocked(samples)
+			app.wakeBufferMutex.Unlock()
+			return
+		}
+		app.wakeBufferMutex.Unlock()
 
-	case StateActive:
+		app.requestIDMutex.RLock()
+		reqID := app.currentRequestID
+		app.requestIDMutex.RUnlock()
+
+		app.sendAudioStream(reqID, samples)
+
+	case session.StateActive:
 		// Append to silence detection buffer
 		app.appendToSilenceBuffer(samples)
 
@@ -465,30 +848,129 @@ func (app *App) handleGpioAudioChunk(samples []int16) {
 		reqID := app.currentRequestID
 		app.requestIDMutex.RUnlock()
 
-		wavData := app.recorder.ConvertToWAV(samples)
+		app.sendAudioStream(reqID, samples)
+	}
+}
+
+// spotWakeWord runs the keyword spotter over the audio that was just added
+// to the wake buffer and starts a session on a detection
+func (app *App) spotWakeWord(samples []int16) {
+	detection := app.spotter.Process(samples)
+	if detection == nil {
+		return
+	}
+	log.Printf("Wake word %q detected (distance %.3f)", detection.Keyword, detection.Score)
+
+	// The wake path may block on the cancel cooldown
+	preRoll := int(app.config.WakeWord.PreRoll.Seconds() * float64(app.config.Audio.SampleRate))
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.wake("Wake word", preRoll)
+	}()
+}
 
+// checkBargeIn watches captured audio while a response plays. Playback is
+// ducked when the user starts talking and the response is stopped if the
+// speech goes on.
+func (app *App) checkBargeIn(samples []int16) {
+	if !app.player.IsPlaying() || !app.bargeInListening() {
+		app.bargeIn.Reset()
+		return
+	}
+
+	minRMS := app.config.Wake.SilenceThresholdRMS
+	if app.sessionMode() {
+		minRMS = app.noiseStatus().SpeechThresholdRMS
+	}
+
+	decision := app.bargeIn.Process(samples, minRMS)
+	switch decision {
+	case bargein.Duck:
+		log.Printf("[BargeIn] User speech during playback, ducking to %.0f%%", app.config.BargeIn.DuckGain*100)
+		app.player.SetVolume(app.config.BargeIn.DuckGain)
+	case bargein.Restore:
+		log.Println("[BargeIn] Speech ended, restoring playback volume")
+		app.player.SetVolume(1)
+	case bargein.Stop:
+		log.Printf("[BargeIn] Speech continued for %v, stopping the response", app.config.BargeIn.StopAfter)
+		// Stopping playback waits for the player; keep the capture callback free
 		app.wg.Add(1)
 		go func() {
 			defer app.wg.Done()
-			if err := app.wsClient.SendAudioStream(reqID, wavData); err != nil {
-				log.Printf("Failed to send audio stream: %v", err)
-			}
+			app.interruptResponse()
 		}()
 	}
+	if decision != bargein.None {
+		app.events.Publish(events.TypeBargeIn, events.SourceApp, events.BargeIn{
+			Decision: decision.String(),
+			Gain:     app.player.Volume(),
+		})
+	}
+}
+
+// bargeInListening reports whether captured audio reaches the server, so a
+// barge-in can continue as the user's next turn
+func (app *App) bargeInListening() bool {
+	if !app.sessionMode() {
+		return true
+	}
+	state := app.session.State()
+	return state == session.StateWaitingResponse || state == session.StateActive
+}
+
+// interruptResponse stops the response after a local barge-in and asks the
+// server to cancel it. In session modes the turn continues as after a
+// server-detected voice interrupt.
+func (app *App) interruptResponse() {
+	app.chats.cancelActive()
+	app.player.StopPlayback()
+	app.player.ClearBuffer()
+
+	app.requestIDMutex.RLock()
+	reqID := app.currentRequestID
+	app.requestIDMutex.RUnlock()
+	if reqID != "" {
+		if err := app.wsClient.SendCancelOutput(reqID); err != nil {
+			log.Printf("Failed to send cancel output: %v", err)
+		}
+	}
+
+	if app.sessionMode() {
+		_, _ = app.session.Fire(session.EventVoiceInterrupt, "Local barge-in, now listening for user input")
+	}
+}
+
+// sendAudioStream encodes samples with the configured codec and queues them for the backend
+func (app *App) sendAudioStream(requestID string, samples []int16) {
+	data, err := app.recorder.EncodeAudio(samples)
+	if err != nil {
+		log.Printf("Failed to encode audio stream: %v", err)
+		return
+	}
+	if err := app.wsClient.SendAudioStream(requestID, data); err != nil {
+		log.Printf("Failed to send audio stream: %v", err)
+	}
 }
 
 // appendToWakeBuffer appends samples to the circular wake buffer, trimming old data
 func (app *App) appendToWakeBuffer(samples []int16) {
 	app.wakeBufferMutex.Lock()
+	app.appendToWakeBufferLocked(samples)
+	app.wakeBufferMutex.Unlock()
+}
+
+// appendToWakeBufferLocked appends to the wake buffer. Must be called with wakeBufferMutex held.
+func (app *App) appendToWakeBufferLocked(samples []int16) {
 	app.wakeBuffer = append(app.wakeBuffer, samples...)
 	if len(app.wakeBuffer) > app.wakeBufferMaxSize {
 		excess := len(app.wakeBuffer) - app.wakeBufferMaxSize
 		app.wakeBuffer = app.wakeBuffer[excess:]
 	}
-	app.wakeBufferMutex.Unlock()
 }
 
-// appendToSilenceBuffer appends samples to the silence detection buffer, trimming old data
+// appendToSilenceBuffer appends samples to the silence detection buffer, trimming old data,
+// and feeds them to the voice activity detector
 func (app *App) appendToSilenceBuffer(samples []int16) {
 	app.silenceBufferMutex.Lock()
 	app.silenceBuffer = append(app.silenceBuffer, samples...)
@@ -496,6 +978,13 @@ func (app *App) appendToSilenceBuffer(samples []int16) {
 		excess := len(app.silenceBuffer) - app.silenceBufferSize
 		app.silenceBuffer = app.silenceBuffer[excess:]
 	}
+	if app.vad != nil {
+		for _, event := range app.vad.Process(samples) {
+			if app.enableDebug {
+				log.Printf("VAD: %s", event)
+			}
+		}
+	}
 	app.silenceBufferMutex.Unlock()
 }
 
@@ -503,128 +992,115 @@ func (app *App) appendToSilenceBuffer(samples []int16) {
 func (app *App) silenceCheckLoop() {
 	defer app.wg.Done()
 
-	ticker := time.NewTicker(app.config.Wake.SilenceCheckInterval)
+	// The VAD tracks silence per frame, so it is checked once per audio chunk
+	interval := app.config.Wake.SilenceCheckInterval
+	if app.vad != nil {
+		interval = app.config.Audio.ChunkDuration
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	const waitingResponseTimeout = 30 * time.Second
-
 	for {
 		select {
 		case <-app.ctx.Done():
 			return
 		case <-ticker.C:
-			currentState := AppState(app.state.Load())
-
-			// Check WaitingResponse timeout: if no audio response for 30s, go to sleep
-			if currentState == StateWaitingResponse {
-				app.waitingResponseMutex.RLock()
-				since := app.waitingResponseSince
-				app.waitingResponseMutex.RUnlock()
-
-				if !since.IsZero() && !app.player.IsPlaying() && time.Since(since) > waitingResponseTimeout {
-					log.Printf("========== [STATE](%s) WaitingResponse timeout (30s), transitioning to SLEEPING ==========", AppState(app.state.Load()))
-
-					// Send inputAudioComplete to signal end of this session's audio
-					// This is done here (not in transitionToWaitingResponse) so the backend's
-					// audio queue stays open during WAITING_RESPONSE for voice interrupt detection.
-					app.requestIDMutex.RLock()
-					reqID := app.currentRequestID
-					app.requestIDMutex.RUnlock()
-
-					if err := app.wsClient.SendAudioComplete(reqID, nil); err != nil {
-						log.Printf("Failed to send audio complete: %v", err)
-					} else if app.enableDebug {
-						log.Println("Sent audio complete signal before sleeping")
-					}
-
-					// Clear wake buffer to start fresh
-					app.wakeBufferMutex.Lock()
-					app.wakeBuffer = app.wakeBuffer[:0]
-					app.wakeBufferMutex.Unlock()
-
-					// Reset the timer
-					app.waitingResponseMutex.Lock()
-					app.waitingResponseSince = time.Time{}
-					app.waitingResponseMutex.Unlock()
-
-					app.state.Store(int32(StateSleeping))
-					log.Printf("========== [STATE](%s) Buffering audio to wake buffer ==========", AppState(app.state.Load()))
+			currentState := app.session.State()
+
+			// Check WaitingResponse timeout: if no audio response for the
+			// response timeout, end the session and go to sleep. inputAudioComplete
+			// is sent on this transition (not on silence) so the backend's audio
+			// queue stays open during WAITING_RESPONSE for voice interrupt detection.
+			if currentState == session.StateWaitingResponse {
+				if !app.player.IsPlaying() && app.session.ResponseTimedOut() {
+					_, _ = app.session.Fire(session.EventResponseTimeout,
+						fmt.Sprintf("WaitingResponse timeout (%v), buffering audio to wake buffer", session.DefaultResponseTimeout))
 				}
 				continue
 			}
 
-			if currentState != StateActive {
-				continue
-			}
-
-			// Copy the silence buffer for checking
-			app.silenceBufferMutex.Lock()
-			bufLen := len(app.silenceBuffer)
-			bufferCopy := make([]int16, bufLen)
-			copy(bufferCopy, app.silenceBuffer)
-			app.silenceBufferMutex.Unlock()
-
-			// Need the full buffer filled before checking (3 seconds of audio data).
-			// This provides a natural grace period after entering Active state,
-			// giving the user time to start speaking or allowing new TTS playback
-			// to begin (which raises the RMS above silence threshold).
-			if bufLen < app.silenceBufferSize {
+			if currentState != session.StateActive {
 				continue
 			}
 
-			// Check if the entire buffer is silent
-			isSilent := utils.IsSilent(
-				bufferCopy,
-				app.config.Wake.SilenceThresholdRMS,
-				app.config.Wake.SilenceRatio,
-			)
-
-			if app.enableDebug {
-				rms := utils.CalculateRMS(bufferCopy)
-				log.Printf("Silence check: RMS=%.2f, threshold=%.2f, silent=%v, samples=%d",
-					rms, app.config.Wake.SilenceThresholdRMS, isSilent, bufLen)
+			var isSilent bool
+			if app.vad != nil {
+				isSilent = app.vadSilence()
+			} else {
+				isSilent = app.rmsSilence()
 			}
 
+			// Audio keeps streaming in WAITING_RESPONSE for interrupt detection
 			if isSilent {
-				app.transitionToWaitingResponse()
+				_, _ = app.session.Fire(session.EventSilence,
+					"Silence detected, audio continues streaming for interrupt detection")
 			}
 		}
 	}
 }
 
-// transitionToWaitingResponse switches state to WaitingResponse without sending
-// inputAudioComplete, so audio continues streaming to the backend for interrupt detection.
-// inputAudioComplete is only sent when the session truly ends (timeout -> SLEEPING).
-func (app *App) transitionToWaitingResponse() {
-	log.Printf("========== [STATE](%s) Silence detected, transitioning to WAITING_RESPONSE ==========", AppState(app.state.Load()))
+// vadSilence reports whether the voice activity detector saw the end of
+// the turn: EndOfSpeechSilence after speech, or the full silence buffer
+// duration if the user has not spoken since entering ACTIVE
+func (app *App) vadSilence() bool {
+	app.silenceBufferMutex.Lock()
+	inSpeech := app.vad.InSpeech()
+	heard := app.vad.HeardSpeech()
+	silence := time.Duration(app.vad.SilenceSamples()) * time.Second / time.Duration(app.config.Audio.SampleRate)
+	app.silenceBufferMutex.Unlock()
 
-	// Clear silence buffer
+	limit := app.config.Wake.EndOfSpeechSilence
+	if !heard {
+		limit = time.Duration(app.config.Wake.SilenceBufferSeconds) * time.Second
+	}
+
+	if app.enableDebug {
+		log.Printf("VAD check: inSpeech=%v, heardSpeech=%v, silence=%v, limit=%v", inSpeech, heard, silence, limit)
+	}
+	return !inSpeech && silence >= limit
+}
+
+// rmsSilence checks whether the whole silence buffer is below the RMS
+// threshold (fallback when the VAD is disabled)
+func (app *App) rmsSilence() bool {
+	// Copy the silence buffer for checking
 	app.silenceBufferMutex.Lock()
-	app.silenceBuffer = app.silenceBuffer[:0]
+	bufLen := len(app.silenceBuffer)
+	bufferCopy := make([]int16, bufLen)
+	copy(bufferCopy, app.silenceBuffer)
 	app.silenceBufferMutex.Unlock()
 
-	// Record when we entered WaitingResponse for timeout tracking
-	app.waitingResponseMutex.Lock()
-	app.waitingResponseSince = time.Now()
-	app.waitingResponseMutex.Unlock()
+	// Need the full buffer filled before checking (3 seconds of audio data).
+	// This provides a natural grace period after entering Active state,
+	// giving the user time to start speaking or allowing new TTS playback
+	// to begin (which raises the RMS above silence threshold).
+	if bufLen < app.silenceBufferSize {
+		return false
+	}
 
-	// Switch to WaitingResponse instead of Sleeping so audio keeps streaming to backend
-	app.state.Store(int32(StateWaitingResponse))
-	log.Printf("========== [STATE](%s) Audio continues streaming for interrupt detection ==========", AppState(app.state.Load()))
+	// Check if the entire buffer is silent
+	threshold := app.noiseStatus().SilenceThresholdRMS
+	isSilent := utils.IsSilent(
+		bufferCopy,
+		threshold,
+		app.config.Wake.SilenceRatio,
+	)
+
+	if app.enableDebug {
+		rms := utils.CalculateRMS(bufferCopy)
+		log.Printf("Silence check: RMS=%.2f, threshold=%.2f, silent=%v, samples=%d",
+			rms, threshold, isSilent, bufLen)
+	}
+	return isSilent
 }
 
 // OnRecordingComplete handles recording completion (for stdin/file modes)
 func (app *App) OnRecordingComplete(requestID string, _ []int16) {
-	app.wg.Add(1)
-	go func() {
-		defer app.wg.Done()
-
-		if err := app.wsClient.SendAudioComplete(requestID, nil); err != nil {
-			log.Printf("Failed to send completion notification: %v", err)
-		} else if app.enableDebug {
-			log.Println("Sent completion request (no remaining audio)")
-		}
-	}()
+	if err := app.wsClient.SendAudioComplete(requestID, nil); err != nil {
+		log.Printf("Failed to send completion notification: %v", err)
+	} else if app.enableDebug {
+		log.Println("Queued completion request (no remaining audio)")
+	}
 }
 
 // === Implementation of websocket.MessageHandler interface ===
@@ -636,9 +1112,14 @@ func (app *App) HandleOutputAudioStream(resp *websocket.OutputAudioStreamRespons
 			resp.ID, resp.Data.ConversationID, resp.Data.ChatID)
 	}
 
-	audioData, err := base64.StdEncoding.DecodeString(resp.Data.Buffer)
+	audioData, err := resp.Audio()
 	if err != nil {
-		log.Printf("Audio decoding failed: %v", err)
+		log.Printf("Audio payload decoding failed: %v", err)
+		return
+	}
+
+	// Drop late audio of a cancelled or superseded chat
+	if !app.chats.accept(resp.Data.ChatID, resp.Data.ConversationID, outputAudio, len(audioData)) {
 		return
 	}
 
@@ -648,14 +1129,14 @@ func (app *App) HandleOutputAudioStream(resp *websocket.OutputAudioStreamRespons
 
 	// Reset WaitingResponse timer when receiving response audio
 	// This prevents timeout while audio is actively being received
-	if app.controlMode == "gpio" && AppState(app.state.Load()) == StateWaitingResponse {
-		app.waitingResponseMutex.Lock()
-		app.waitingResponseSince = time.Now()
-		app.waitingResponseMutex.Unlock()
+	if app.sessionMode() {
+		app.session.ResponseActivity()
 	}
 
-	// Write to playback buffer
-	app.player.WriteAudioData(audioData)
+	// Decode with the configured codec and write to playback buffer
+	if err := app.player.WriteEncodedAudio(audioData); err != nil {
+		log.Printf("Failed to play audio: %v", err)
+	}
 }
 
 // HandleOutputAudioComplete handles output audio completion
@@ -664,17 +1145,14 @@ func (app *App) HandleOutputAudioComplete(resp *websocket.OutputAudioCompleteRes
 		log.Printf("Audio output complete: ConversationID=%s, ChatID=%s",
 			resp.Data.ConversationID, resp.Data.ChatID)
 	}
+	if !app.chats.accept(resp.Data.ChatID, resp.Data.ConversationID, outputOther, 0) {
+		return
+	}
 	app.player.SetAudioComplete(true)
 
 	// In GPIO mode, transition from WaitingResponse to Active after audio playback completes
-	if app.controlMode == "gpio" && AppState(app.state.Load()) == StateWaitingResponse {
-		// Clear silence buffer before starting silence detection
-		app.silenceBufferMutex.Lock()
-		app.silenceBuffer = app.silenceBuffer[:0]
-		app.silenceBufferMutex.Unlock()
-
-		app.state.Store(int32(StateActive))
-		log.Printf("========== [STATE](%s) Now listening for user input ==========", AppState(app.state.Load()))
+	if app.sessionMode() {
+		_, _ = app.session.Fire(session.EventResponseComplete, "Now listening for user input")
 	}
 }
 
@@ -684,6 +1162,13 @@ func (app *App) HandleOutputTextStream(resp *websocket.OutputTextStreamResponse)
 		log.Printf("Received valid text stream: ID=%s, Role=%s, Text=%s",
 			resp.Data.ChatID, resp.Data.Role, resp.Data.Text)
 	}
+	if !app.chats.accept(resp.Data.ChatID, resp.Data.ConversationID, outputText, 0) {
+		return
+	}
+	app.conversation.Touch()
+	if len(resp.Data.Text) > 0 {
+		app.publishTranscript(events.TypeTranscriptStream, resp.Data.ChatID, resp.Data.ConversationID, resp.Data.Role, resp.Data.Text)
+	}
 
 	// If it's a user message with text length >= 2, a new user message was sent; execute interruption logic
 	if resp.Data.Role == "user" && len(resp.Data.Text) >= 2 {
@@ -698,10 +1183,24 @@ func (app *App) HandleOutputTextStream(resp *websocket.OutputTextStreamResponse)
 
 // HandleOutputTextComplete handles output text completion
 func (app *App) HandleOutputTextComplete(resp *websocket.OutputTextCompleteResponse) {
+	if !app.chats.accept(resp.Data.ChatID, resp.Data.ConversationID, outputText, 0) {
+		return
+	}
 	if app.enableDebug {
 		log.Printf("Text output complete: ID=%s, Role=%s, Text=%s",
 			resp.Data.ChatID, resp.Data.Role, resp.Data.Text)
 	}
+	app.publishTranscript(events.TypeTranscriptComplete, resp.Data.ChatID, resp.Data.ConversationID, resp.Data.Role, resp.Data.Text)
+}
+
+// publishTranscript publishes chat text to the event bus
+func (app *App) publishTranscript(t events.Type, chatID, conversationID, role, text string) {
+	app.events.Publish(t, events.SourceApp, events.Transcript{
+		ChatID:         chatID,
+		ConversationID: conversationID,
+		Role:           role,
+		Text:           text,
+	})
 }
 
 // HandleChatComplete handles chat completion
@@ -710,17 +1209,30 @@ func (app *App) HandleChatComplete(resp *websocket.ChatCompleteResponse) {
 		log.Printf("Chat complete: ID=%s, Success=%v, Message=%s",
 			resp.Data.ChatID, resp.Success, resp.Message)
 	}
+	if !app.chats.accept(resp.Data.ChatID, resp.Data.ConversationID, outputOther, 0) {
+		return
+	}
+	app.conversation.Set(resp.Data.ConversationID)
 
+	complete := events.ChatComplete{
+		ChatID:         resp.Data.ChatID,
+		ConversationID: resp.Data.ConversationID,
+		Success:        resp.Success,
+		Message:        resp.Message,
+	}
 	if !resp.Success {
 		for _, err := range resp.Data.Errors {
 			log.Printf("Error [%d]: %s", err.Code, err.Message)
+			complete.Errors = append(complete.Errors, events.ChatError{Code: err.Code, Message: err.Message})
 		}
 	}
+	app.events.Publish(events.TypeChatComplete, events.SourceApp, complete)
 }
 
-// HandleCancelOutput handles cancel output from server (voice interrupt)
+// HandleCancelOutput handles a cancel initiated by the server (voice interrupt)
 func (app *App) HandleCancelOutput(resp *websocket.CancelOutputResponse) {
 	log.Printf("[App] Received cancelOutput from server (type: %s), stopping playback", resp.Data.CancelType)
+	app.chats.cancelActive()
 
 	// Stop audio playback immediately
 	if app.player.IsPlaying() {
@@ -733,56 +1245,180 @@ func (app *App) HandleCancelOutput(resp *websocket.CancelOutputResponse) {
 	// In GPIO mode, a voice-type cancel means the server detected user speech.
 	// Transition to Active so silence detection can properly end the session
 	// instead of relying on the 30s WaitingResponse timeout.
-	if app.controlMode == "gpio" && resp.Data.CancelType == "voice" {
-		if AppState(app.state.Load()) == StateWaitingResponse {
-			// Clear silence buffer to start fresh for the new active session
-			app.silenceBufferMutex.Lock()
-			app.silenceBuffer = app.silenceBuffer[:0]
-			app.silenceBufferMutex.Unlock()
+	if app.sessionMode() && resp.Data.CancelType == "voice" {
+		_, _ = app.session.Fire(session.EventVoiceInterrupt, "Voice interrupt detected, now listening for user input")
+	}
+}
 
-			// Reset waiting response timer since we're leaving that state
-			app.waitingResponseMutex.Lock()
-			app.waitingResponseSince = time.Time{}
-			app.waitingResponseMutex.Unlock()
+// HandleConnected handles a successful (re)connection
+func (app *App) HandleConnected(event websocket.ConnectionEvent) {
+	log.Printf("[App] WebSocket connected to %s (attempt %d)", event.Endpoint, event.Attempt)
+}
 
-			app.state.Store(int32(StateActive))
-			log.Printf("========== [STATE](%s) Voice interrupt detected, now listening for user input ==========", AppState(app.state.Load()))
-		}
+// HandleReconnecting handles a failed connection attempt
+func (app *App) HandleReconnecting(event websocket.ConnectionEvent) {
+	if app.enableDebug {
+		log.Printf("[App] WebSocket reconnecting: attempt %d to %s failed (%s), next in %v",
+			event.Attempt, event.Endpoint, event.Reason, event.Delay)
 	}
 }
 
-// HandleUpdateConfig handles update config response
-func (app *App) HandleUpdateConfig(resp *websocket.UpdateConfigResponse) {
-	if app.enableDebug {
-		log.Printf("Received config update response: Success=%v, Message=%s", resp.Success, resp.Message)
+// HandleDisconnected handles loss of the WebSocket connection. Without
+// offline buffering the current GPIO session cannot continue, so it is
+// aborted. With offline buffering the session is paused: playback stops,
+// captured audio is held for replay, and HandleTurnLost aborts the session
+// if the link does not come back in time.
+func (app *App) HandleDisconnected(event websocket.ConnectionEvent) {
+	log.Printf("[App] WebSocket disconnected: %s", event.Reason)
+
+	if !app.sessionMode() {
+		if app.recorder.IsRecording() && app.config.WebSocket.OfflineBufferBytes == 0 {
+			log.Println("Recording in progress, audio cannot be delivered until the connection is restored")
+		}
+		return
 	}
-	// Non-blocking send; if nobody is waiting, the signal is dropped.
-	select {
-	case app.updateCh <- struct{}{}:
-	default:
+
+	if app.session.State() == session.StateSleeping {
+		return
 	}
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+
+		if app.config.WebSocket.OfflineBufferBytes == 0 {
+			app.returnToSleeping("connection lost")
+			return
+		}
+
+		// Response audio will not resume on a new connection
+		if app.player.IsPlaying() {
+			app.player.StopPlayback()
+		}
+		log.Printf("========== [STATE](%s) Connection lost, session paused until reconnect ==========", app.session.State())
+	}()
 }
 
-// sendUpdateConfigAndWait sends a config update request and waits for response
-func (app *App) sendUpdateConfigAndWait(requestID string) {
-	if err := app.wsClient.SendUpdateConfig(requestID, &app.config.Device); err != nil {
-		log.Printf("Failed to send config update: %v", err)
+// HandleTurnLost handles a buffered turn that was discarded before it could
+// be sent (offline buffer expired or overflowed during a reconnect)
+func (app *App) HandleTurnLost(requestID string, reason string) {
+	log.Printf("[App] Turn %s was lost (%s)", requestID, reason)
+
+	if !app.sessionMode() {
+		log.Println("The last utterance was not delivered, please try again")
 		return
 	}
 
+	app.requestIDMutex.RLock()
+	current := app.currentRequestID
+	app.requestIDMutex.RUnlock()
+
+	if requestID == current && app.session.State() != session.StateSleeping {
+		app.returnToSleeping("turn lost")
+	}
+}
+
+// returnToSleeping abandons the current GPIO session without notifying the
+// backend: playback stops, all buffers are cleared and the state machine
+// goes back to SLEEPING
+func (app *App) returnToSleeping(reason string) {
+	app.chats.cancelActive()
+	_, _ = app.session.Fire(session.EventAbort,
+		fmt.Sprintf("Session abandoned (%s), buffering audio to wake buffer", reason))
+}
+
+// OnSessionAction performs the actions of a session state transition
+func (app *App) OnSessionAction(action session.Action, _ session.Transition) {
+	switch action {
+	case session.ActionStopPlayback:
+		if app.player.IsPlaying() {
+			log.Println("Interrupting audio playback")
+			app.player.StopPlayback()
+		}
+
+	case session.ActionClearSilenceBuffer:
+		// Only a measured noise floor raises the VAD minimum; the fixed
+		// silence threshold is far above what the VAD needs
+		noise := app.noiseStatus()
+		minLevel := 0.0
+		if noise.Adaptive && noise.Ready {
+			minLevel = noise.SpeechThresholdRMS
+		}
+		app.silenceBufferMutex.Lock()
+		app.silenceBuffer = app.silenceBuffer[:0]
+		if app.vad != nil {
+			app.vad.Reset()
+			app.vad.SetMinLevel(minLevel)
+		}
+		app.silenceBufferMutex.Unlock()
+
+	case session.ActionClearWakeBuffer:
+		app.wakeBufferMutex.Lock()
+		app.wakeBuffer = app.wakeBuffer[:0]
+		app.wakeBufferMutex.Unlock()
+
+	case session.ActionSendAudioComplete:
+		// Signal the end of this session's audio
+		app.requestIDMutex.RLock()
+		reqID := app.currentRequestID
+		app.requestIDMutex.RUnlock()
+
+		if err := app.wsClient.SendAudioComplete(reqID, nil); err != nil {
+			log.Printf("Failed to send audio complete: %v", err)
+		} else if app.enableDebug {
+			log.Println("Sent audio complete signal before sleeping")
+		}
+	}
+}
+
+// logTransition logs session state changes and publishes them to the event bus
+func (app *App) logTransition(t session.Transition) {
+	log.Printf("========== [STATE](%s) %s ==========", t.To, t.Reason)
+	app.events.Publish(events.TypeStateChanged, events.SourceApp, events.StateChange{
+		From:   t.From.String(),
+		To:     t.To.String(),
+		Event:  t.Event.String(),
+		Reason: t.Reason,
+	})
+}
+
+// HandleUpdateConfig handles update config response
+func (app *App) HandleUpdateConfig(resp *websocket.UpdateConfigResponse) {
 	if app.enableDebug {
-		log.Println("Update request sent")
+		log.Printf("Received config update response: Success=%v, Message=%s", resp.Success, resp.Message)
 	}
+}
 
-	// Wait for update response or context cancellation
-	select {
-	case <-app.updateCh:
-		// Response received
-	case <-app.ctx.Done():
-		return
+// sendUpdateConfigAndWait sends a config update request and waits for the
+// matching response. The error says whether the request failed, timed out
+// or was rejected by the server.
+func (app *App) sendUpdateConfigAndWait(requestID string) error {
+	conversationID := app.conversation.ID()
+	device := app.deviceConfig()
+	resp, err := app.wsClient.UpdateConfig(app.ctx, requestID, &device, conversationID)
+
+	var serverErr *websocket.ServerError
+	if errors.As(err, &serverErr) && conversationID != "" {
+		// The backend may no longer know the conversation; start a new one
+		log.Printf("Config update with conversation %s rejected (%s), starting a new conversation",
+			conversationID, serverErr.Message)
+		app.conversation.Clear()
+		resp, err = app.wsClient.UpdateConfig(app.ctx, requestID, &device, "")
 	}
 
+	if err != nil {
+		if errors.As(err, &serverErr) {
+			log.Printf("Config update rejected: %s", serverErr.Message)
+		} else if app.ctx.Err() == nil {
+			log.Printf("Config update failed: %v", err)
+		}
+		return fmt.Errorf("config update failed: %w", err)
+	}
+	app.conversation.Set(resp.Data.ConversationId)
+	app.chats.activateConversation(resp.Data.ConversationId)
+
 	if app.enableDebug {
 		log.Println("Update response successful, starting streaming audio transmission")
 	}
+	return nil
 }
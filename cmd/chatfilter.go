@@ -0,0 +1,172 @@
+package main
+
+import (
+	"log"
+	"sync"
+)
+
+// maxStaleChats bounds how many cancelled chat and conversation IDs are remembered
+const maxStaleChats = 32
+
+// StaleOutputStats counts downlink messages discarded because their chat
+// was cancelled or superseded
+type StaleOutputStats struct {
+	DroppedAudioMessages int   `json:"droppedAudioMessages"`
+	DroppedAudioBytes    int64 `json:"droppedAudioBytes"`
+	DroppedTextMessages  int   `json:"droppedTextMessages"`
+	DroppedOtherMessages int   `json:"droppedOtherMessages"` // audioComplete and chatComplete
+	CancelledChats       int   `json:"cancelledChats"`
+}
+
+// chatFilter tracks the active chat and conversation and rejects late
+// output of chats that were cancelled or superseded by a newer chat
+type chatFilter struct {
+	activeChatID         string
+	activeConversationID string
+	staleChats           []string // Oldest first, at most maxStaleChats
+	staleConversations   []string
+	stats                StaleOutputStats
+	enableDebug          bool
+	mutex                sync.Mutex
+}
+
+// Output kinds for counting dropped messages
+const (
+	outputAudio = iota
+	outputText
+	outputOther
+)
+
+// newChatFilter creates a chat filter
+func newChatFilter(enableDebug bool) *chatFilter {
+	return &chatFilter{enableDebug: enableDebug}
+}
+
+// accept reports whether output of the given chat should be processed. A
+// new chat ID supersedes the previously active chat. size is the audio
+// payload size for audio messages.
+func (f *chatFilter) accept(chatID, conversationID string, kind int, size int) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.isStale(chatID, conversationID) {
+		switch kind {
+		case outputAudio:
+			f.stats.DroppedAudioMessages++
+			f.stats.DroppedAudioBytes += int64(size)
+		case outputText:
+			f.stats.DroppedTextMessages++
+		default:
+			f.stats.DroppedOtherMessages++
+		}
+		if f.enableDebug {
+			log.Printf("Dropping stale output of chat %s (%d audio message(s), %d bytes dropped so far)",
+				chatID, f.stats.DroppedAudioMessages, f.stats.DroppedAudioBytes)
+		}
+		return false
+	}
+
+	if chatID != "" && chatID != f.activeChatID {
+		if f.activeChatID != "" {
+			f.markStale(f.activeChatID)
+			if f.enableDebug {
+				log.Printf("Chat %s superseded by %s", f.activeChatID, chatID)
+			}
+		}
+		f.activeChatID = chatID
+	}
+	if conversationID != "" {
+		f.activeConversationID = conversationID
+	}
+	return true
+}
+
+// cancelActive marks the active chat as cancelled so its remaining output is dropped
+func (f *chatFilter) cancelActive() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.activeChatID == "" {
+		return
+	}
+	f.markStale(f.activeChatID)
+	f.stats.CancelledChats++
+	log.Printf("Chat %s cancelled, discarding its remaining output", f.activeChatID)
+	f.activeChatID = ""
+}
+
+// cancelConversation cancels the active chat and drops all further output
+// of the active conversation
+func (f *chatFilter) cancelConversation() {
+	f.cancelActive()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.activeConversationID == "" {
+		return
+	}
+	f.staleConversations = appendBounded(f.staleConversations, f.activeConversationID)
+	f.activeConversationID = ""
+}
+
+// activateConversation makes the conversation confirmed by the server the
+// active one, even if it was cancelled before (the server may keep the ID
+// across a context clear)
+func (f *chatFilter) activateConversation(conversationID string) {
+	if conversationID == "" {
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for i, id := range f.staleConversations {
+		if id == conversationID {
+			f.staleConversations = append(f.staleConversations[:i], f.staleConversations[i+1:]...)
+			break
+		}
+	}
+	f.activeConversationID = conversationID
+}
+
+// snapshot returns the current counters
+func (f *chatFilter) snapshot() StaleOutputStats {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.stats
+}
+
+// isStale checks the cancelled sets. Must be called with mutex held.
+func (f *chatFilter) isStale(chatID, conversationID string) bool {
+	if chatID != "" && contains(f.staleChats, chatID) {
+		return true
+	}
+	return conversationID != "" && contains(f.staleConversations, conversationID)
+}
+
+// markStale remembers a cancelled chat. Must be called with mutex held.
+func (f *chatFilter) markStale(chatID string) {
+	if !contains(f.staleChats, chatID) {
+		f.staleChats = appendBounded(f.staleChats, chatID)
+	}
+}
+
+// appendBounded appends id and drops the oldest entries beyond maxStaleChats
+func appendBounded(ids []string, id string) []string {
+	ids = append(ids, id)
+	if len(ids) > maxStaleChats {
+		ids = ids[len(ids)-maxStaleChats:]
+	}
+	return ids
+}
+
+// contains reports whether ids holds id
+func contains(ids []string, id string) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
@@ -10,11 +10,16 @@ import (
 
 func main() {
 	// Parse command-line flags
-	controlMode := flag.String("mode", "gpio", "Control mode: gpio, stdin, or file")
+	controlMode := flag.String("mode", "gpio", "Control mode: gpio, wakeword, stdin, file, or http")
+	replayPath := flag.String("replay", "", "Replay inbound messages from a capture file instead of connecting")
+	replaySpeed := flag.Float64("replay-speed", 1, "Replay speed factor (0 replays without delays)")
 	flag.Parse()
 
 	// Create application instance
 	app := NewApp(*controlMode)
+	if *replayPath != "" {
+		app.SetReplay(*replayPath, *replaySpeed)
+	}
 
 	// Start application
 	if err := app.Start(); err != nil {
@@ -25,6 +30,15 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGUSR1 logs the status, also in GPIO and wakeword modes without a console
+	statusChan := make(chan os.Signal, 1)
+	signal.Notify(statusChan, syscall.SIGUSR1)
+	go func() {
+		for range statusChan {
+			app.LogStatus()
+		}
+	}()
+
 	// Wait for exit signal or application to finish
 	doneCh := make(chan struct{})
 	go func() {
@@ -0,0 +1,56 @@
+// Command mockserver runs a local scripted chat backend for offline testing.
+//
+// Point websocket_url in config.toml at ws://127.0.0.1:10580 and start the
+// client as usual. A server-initiated voice interrupt can be triggered with:
+//
+//	curl -X POST 'http://127.0.0.1:10580/mock/cancel?type=voice'
+//
+// Clients can be asked to reconnect elsewhere or back off with:
+//
+//	curl -X POST 'http://127.0.0.1:10580/mock/reconnect?url=ws://127.0.0.1:10581/api/v1/chat/ws&retryAfter=30'
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"websocket_client_chat/internal/mockserver"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:10580", "Listen address")
+	path := flag.String("path", "/api/v1/chat/ws", "WebSocket endpoint path")
+	scriptPath := flag.String("script", "", "JSON script file (built-in script if empty)")
+	token := flag.String("token", "", "Required access token (any token accepted if empty)")
+	binary := flag.Bool("binary", false, "Accept binary PCM audio frames when the client offers them")
+	debug := flag.Bool("debug", false, "Log every protocol message")
+	flag.Parse()
+
+	script := mockserver.DefaultScript()
+	if *scriptPath != "" {
+		var err error
+		script, err = mockserver.LoadScript(*scriptPath)
+		if err != nil {
+			log.Fatalf("Failed to load script: %v", err)
+		}
+		log.Printf("Loaded script %s (%d turns)", *scriptPath, len(script.Turns))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	server := mockserver.NewServer(mockserver.Config{
+		Addr:        *addr,
+		Path:        *path,
+		Token:       *token,
+		BinaryAudio: *binary,
+	}, script, *debug)
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Mock server failed: %v", err)
+	}
+}
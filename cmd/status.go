@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+
+	"websocket_client_chat/internal/config"
+	"websocket_client_chat/internal/websocket"
+	"websocket_client_chat/pkg/aec"
+)
+
+// Status is a snapshot of the application state for diagnostics
+type Status struct {
+	ControlMode    string                     `json:"controlMode"`
+	State          string                     `json:"state,omitempty"` // Session state in gpio and wakeword modes
+	Connected      bool                       `json:"connected"`
+	Endpoints      []websocket.EndpointStatus `json:"endpoints"`
+	ConversationID string                     `json:"conversationId,omitempty"`
+	RequestID      string                     `json:"requestId,omitempty"`
+	Recording      bool                       `json:"recording"`
+	Playing        bool                       `json:"playing"`
+	Volume         float64                    `json:"volume"` // Output volume (0-1)
+	Noise          *NoiseStatus               `json:"noise,omitempty"`
+	Echo           *aec.Stats                 `json:"echo,omitempty"` // Echo canceller, when enabled
+	StaleOutput    StaleOutputStats           `json:"staleOutput"`
+}
+
+// NoiseStatus reports the ambient noise estimate and the thresholds derived from it
+type NoiseStatus struct {
+	Adaptive            bool    `json:"adaptive"`
+	FloorRMS            float64 `json:"floorRms"`
+	Ready               bool    `json:"ready"` // Enough audio measured; until then the fixed threshold is used
+	SilenceThresholdRMS float64 `json:"silenceThresholdRms"`
+	SpeechThresholdRMS  float64 `json:"speechThresholdRms"`
+}
+
+// Status returns the current application status
+func (app *App) Status() Status {
+	app.requestIDMutex.RLock()
+	requestID := app.currentRequestID
+	app.requestIDMutex.RUnlock()
+
+	status := Status{
+		ControlMode:    app.controlMode,
+		Connected:      app.wsClient.IsConnected(),
+		Endpoints:      app.wsClient.EndpointStatus(),
+		ConversationID: app.conversation.ID(),
+		RequestID:      requestID,
+		Recording:      app.recorder.IsRecording(),
+		Playing:        app.player.IsPlaying(),
+		Volume:         app.player.OutputVolume(),
+		StaleOutput:    app.StaleOutputStats(),
+	}
+	if app.echo != nil {
+		echo := app.echo.Stats()
+		status.Echo = &echo
+	}
+	if app.sessionMode() {
+		status.State = app.session.State().String()
+		noise := app.noiseStatus()
+		status.Noise = &noise
+	}
+	return status
+}
+
+// LogStatus writes the current status to the log
+func (app *App) LogStatus() {
+	data, err := json.MarshalIndent(app.Status(), "", "  ")
+	if err != nil {
+		log.Printf("Failed to encode status: %v", err)
+		return
+	}
+	log.Printf("Status:\n%s", data)
+}
+
+// trackNoiseFloor measures ambient noise while SLEEPING and logs the
+// estimate when it changes by more than 3 dB
+func (app *App) trackNoiseFloor(samples []int16) {
+	// Response playback is not ambient noise
+	if app.player.IsPlaying() {
+		return
+	}
+
+	app.noiseMutex.Lock()
+	app.noiseFloor.Update(samples)
+	floor, ready := app.noiseFloor.Estimate()
+	changed := ready && (app.noiseLogged == 0 || math.Abs(20*math.Log10((floor+1)/(app.noiseLogged+1))) > 3)
+	if changed {
+		app.noiseLogged = floor
+	}
+	app.noiseMutex.Unlock()
+
+	if changed {
+		noise := app.noiseStatus()
+		log.Printf("Noise floor: RMS %.1f, silence threshold %.1f, speech threshold %.1f",
+			noise.FloorRMS, noise.SilenceThresholdRMS, noise.SpeechThresholdRMS)
+	}
+}
+
+// noiseStatus returns the noise floor and the silence and speech thresholds
+// in effect. Without an estimate the fixed SilenceThresholdRMS applies.
+func (app *App) noiseStatus() NoiseStatus {
+	cfg := &app.config.Wake
+	status := NoiseStatus{
+		Adaptive:            cfg.AdaptiveThreshold,
+		SilenceThresholdRMS: cfg.SilenceThresholdRMS,
+		SpeechThresholdRMS:  cfg.SilenceThresholdRMS,
+	}
+
+	app.noiseMutex.Lock()
+	status.FloorRMS, status.Ready = app.noiseFloor.Estimate()
+	app.noiseMutex.Unlock()
+
+	if cfg.AdaptiveThreshold && status.Ready {
+		status.SilenceThresholdRMS = clampThreshold(status.FloorRMS*cfg.SilenceMargin, cfg)
+		status.SpeechThresholdRMS = clampThreshold(status.FloorRMS*cfg.SpeechMargin, cfg)
+	}
+	return status
+}
+
+// clampThreshold limits a derived threshold to the configured bounds
+func clampThreshold(rms float64, cfg *config.WakeConfig) float64 {
+	if cfg.MaxThresholdRMS > 0 && rms > cfg.MaxThresholdRMS {
+		rms = cfg.MaxThresholdRMS
+	}
+	if rms < cfg.MinThresholdRMS {
+		rms = cfg.MinThresholdRMS
+	}
+	return rms
+}
@@ -2,12 +2,18 @@ package audio
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"websocket_client_chat/internal/config"
+	"websocket_client_chat/internal/events"
+	"websocket_client_chat/pkg/aec"
 	"websocket_client_chat/pkg/buffer"
+	"websocket_client_chat/pkg/codec"
 
 	"github.com/gordonklaus/portaudio"
 )
@@ -28,6 +34,22 @@ type Player struct {
 	// Playback stream
 	stream *portaudio.Stream
 
+	// Downlink audio decoder (PCM passthrough by default)
+	decoder    codec.Decoder
+	decoderErr error
+
+	// Echo canceller receiving every played sample as its reference (optional)
+	echoRef *aec.Canceller
+
+	// Target output gain (math.Float64bits), ramped to in the callback
+	volume atomic.Uint64
+
+	// Output volume (math.Float64bits) applied on top of the gain, kept across playbacks
+	outputVolume atomic.Uint64
+
+	// Event bus receiving playback starts and stops (optional)
+	events *events.Bus
+
 	// Context control
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -40,13 +62,61 @@ type Player struct {
 func NewPlayer(parentCtx context.Context, cfg *config.AudioConfig, enableDebug bool) *Player {
 	ctx, cancel := context.WithCancel(parentCtx)
 
-	return &Player{
+	decoder, err := codec.NewDecoder(codecConfig(cfg))
+	if err != nil {
+		log.Printf("Failed to create %s decoder: %v", cfg.Codec, err)
+	}
+
+	p := &Player{
 		config:      cfg,
 		audioBuffer: buffer.New(cfg.BufferSize),
+		decoder:     decoder,
+		decoderErr:  err,
 		ctx:         ctx,
 		cancel:      cancel,
 		enableDebug: enableDebug,
 	}
+	p.volume.Store(math.Float64bits(1))
+	p.outputVolume.Store(math.Float64bits(1))
+	return p
+}
+
+// volumeRamp is the time a volume change takes, short enough to feel
+// immediate and long enough not to click
+const volumeRamp = 20 * time.Millisecond
+
+// SetVolume sets the output gain (0-1) of the current playback, e.g. to
+// duck it while the user talks. Each new playback starts at full volume.
+func (p *Player) SetVolume(gain float64) {
+	p.volume.Store(math.Float64bits(math.Max(0, math.Min(1, gain))))
+}
+
+// Volume returns the target output gain
+func (p *Player) Volume() float64 {
+	return math.Float64frombits(p.volume.Load())
+}
+
+// SetOutputVolume sets the output volume (0-1). Unlike SetVolume it stays
+// in effect for later playbacks.
+func (p *Player) SetOutputVolume(volume float64) {
+	p.outputVolume.Store(math.Float64bits(math.Max(0, math.Min(1, volume))))
+}
+
+// OutputVolume returns the output volume
+func (p *Player) OutputVolume() float64 {
+	return math.Float64frombits(p.outputVolume.Load())
+}
+
+// SetEchoReference feeds the samples handed to the audio device to the echo
+// canceller. Must be called before playback starts.
+func (p *Player) SetEchoReference(echo *aec.Canceller) {
+	p.echoRef = echo
+}
+
+// SetEventBus publishes playback starts and stops to bus. Must be called
+// before playback starts.
+func (p *Player) SetEventBus(bus *events.Bus) {
+	p.events = bus
 }
 
 // Stop stops the player
@@ -71,6 +141,28 @@ func (p *Player) Stop() error {
 	p.mutex.Unlock()
 
 	p.audioBuffer.Close()
+
+	if p.decoder != nil {
+		if err := p.decoder.Close(); err != nil {
+			log.Printf("Warning: failed to close audio decoder: %v", err)
+		}
+	}
+	return nil
+}
+
+// WriteEncodedAudio decodes a payload received in the configured wire codec
+// and writes the resulting PCM to the playback buffer
+func (p *Player) WriteEncodedAudio(data []byte) error {
+	if p.decoder == nil {
+		return fmt.Errorf("no audio decoder: %w", p.decoderErr)
+	}
+
+	pcm, err := p.decoder.Decode(data)
+	if err != nil {
+		return fmt.Errorf("audio decoding failed: %w", err)
+	}
+
+	p.WriteAudioData(pcm)
 	return nil
 }
 
@@ -88,10 +180,12 @@ func (p *Player) WriteAudioData(audioData []byte) {
 			log.Println("Starting playback...")
 		}
 		p.isPlaying = true
+		p.SetVolume(1)
 		// Create interrupt channel before starting goroutine to avoid race condition
 		p.interrupted = make(chan struct{})
 		p.playbackWg.Add(1)
 		go p.playAudio()
+		p.events.Publish(events.TypePlaybackStarted, events.SourcePlayer, events.Playback{})
 	}
 	p.mutex.Unlock()
 }
@@ -213,6 +307,7 @@ func (p *Player) playAudio() {
 
 		// Signal that playback goroutine has finished
 		p.playbackWg.Done()
+		p.events.Publish(events.TypePlaybackStopped, events.SourcePlayer, events.Playback{Interrupted: wasInterrupted})
 
 		if p.enableDebug {
 			if wasInterrupted {
@@ -227,6 +322,8 @@ func (p *Player) playAudio() {
 	var shouldStop bool
 	emptyCount := 0
 	lastDataTime := time.Now()
+	gain := p.Volume() * p.OutputVolume()
+	gainStep := 1 / (volumeRamp.Seconds() * float64(p.config.SampleRate))
 
 	// Check if already interrupted before opening stream
 	select {
@@ -269,6 +366,23 @@ func (p *Player) playAudio() {
 				}
 			}
 
+			// Apply the volume, ramping towards a changed target
+			if target := p.Volume() * p.OutputVolume(); gain != 1 || target != 1 {
+				for i := range out {
+					if gain < target {
+						gain = math.Min(target, gain+gainStep)
+					} else if gain > target {
+						gain = math.Max(target, gain-gainStep)
+					}
+					out[i] = int16(float64(out[i]) * gain)
+				}
+			}
+
+			// The echo reference is exactly what the device plays
+			if p.echoRef != nil {
+				p.echoRef.PushFarEnd(out)
+			}
+
 			// Check stop conditions
 			p.completeMutex.RLock()
 			complete := p.audioComplete
@@ -8,17 +8,29 @@ import (
 	"sync"
 	"time"
 	"websocket_client_chat/internal/config"
+	"websocket_client_chat/pkg/aec"
+	"websocket_client_chat/pkg/codec"
 	"websocket_client_chat/pkg/utils"
 
 	"github.com/gordonklaus/portaudio"
 )
 
+// chunkQueueSize bounds how many captured chunks may wait for the handler
+// before the audio callback starts dropping them.
+const chunkQueueSize = 32
+
 // Handler defines the audio data handler interface
 type Handler interface {
 	OnAudioChunk(requestID string, samples []int16, isLast bool)
 	OnRecordingComplete(requestID string, samples []int16)
 }
 
+// capturedChunk is one output chunk waiting for delivery to the handler
+type capturedChunk struct {
+	requestID string
+	samples   []int16
+}
+
 // Recorder is the audio recorder
 type Recorder struct {
 	config  *config.AudioConfig
@@ -41,6 +53,17 @@ type Recorder struct {
 	resampleBuffer     []int16 // Buffer for resampling
 	streamingMutex     sync.Mutex
 
+	// Chunks handed from the audio callback to the delivery goroutine
+	chunkQueue    chan capturedChunk
+	chunkDone     chan struct{}
+	droppedChunks int
+
+	// Uplink audio encoder (WAV/PCM by default)
+	encoder codec.Encoder
+
+	// Echo canceller applied before chunks reach the handler (optional)
+	echo *aec.Canceller
+
 	// Debug mode
 	enableDebug bool
 }
@@ -55,6 +78,12 @@ func NewRecorder(cfg *config.AudioConfig, handler Handler, enableDebug bool) *Re
 	}
 }
 
+// SetEchoCanceller removes the playback echo from captured chunks. The
+// player must feed the same canceller. Must be called before recording.
+func (r *Recorder) SetEchoCanceller(echo *aec.Canceller) {
+	r.echo = echo
+}
+
 // Initialize initializes the audio device
 func (r *Recorder) Initialize() error {
 	if r.deviceInitialized {
@@ -88,6 +117,15 @@ func (r *Recorder) Initialize() error {
 		return err
 	}
 
+	// Create the uplink encoder; an unavailable codec is a startup error
+	if r.encoder == nil {
+		encoder, err := codec.NewEncoder(codecConfig(r.config))
+		if err != nil {
+			return fmt.Errorf("failed to create %s encoder: %w", r.config.Codec, err)
+		}
+		r.encoder = encoder
+	}
+
 	r.deviceInitialized = true
 	return nil
 }
@@ -275,6 +313,13 @@ func (r *Recorder) Terminate() error {
 	}
 	r.mutex.Unlock()
 
+	if r.encoder != nil {
+		if err := r.encoder.Close(); err != nil {
+			log.Printf("Warning: failed to close audio encoder: %v", err)
+		}
+		r.encoder = nil
+	}
+
 	if r.isPortAudioInit {
 		err := portaudio.Terminate()
 		r.isPortAudioInit = false
@@ -297,6 +342,11 @@ func (r *Recorder) StartRecording(requestID string) error {
 		return nil // Already recording
 	}
 
+	// Reset the echo canceller before the delivery goroutine owns it
+	if r.echo != nil {
+		r.echo.Reset()
+	}
+
 	// Initialize streaming buffer
 	r.streamingMutex.Lock()
 	r.streamingRequestID = requestID
@@ -304,6 +354,10 @@ func (r *Recorder) StartRecording(requestID string) error {
 	captureChunkSize := int(float64(r.config.CaptureSampleRate) * r.config.ChunkDuration.Seconds())
 	r.streamingBuffer = make([]int16, 0, captureChunkSize*2)
 	r.resampleBuffer = make([]int16, 0, r.config.ChunkSampleCount*2)
+	r.chunkQueue = make(chan capturedChunk, chunkQueueSize)
+	r.chunkDone = make(chan struct{})
+	r.droppedChunks = 0
+	go r.deliverChunks(r.chunkQueue, r.chunkDone)
 	r.streamingMutex.Unlock()
 
 	// Determine the actual sample rate to use
@@ -352,6 +406,7 @@ func (r *Recorder) StartRecording(requestID string) error {
 		}
 		if err != nil {
 			restore()
+			r.stopDelivery()
 			return fmt.Errorf("failed to open audio stream: %w", err)
 		}
 	}
@@ -361,6 +416,7 @@ func (r *Recorder) StartRecording(requestID string) error {
 
 	if startErr := r.stream.Start(); startErr != nil {
 		restore()
+		r.stopDelivery()
 		closeErr := r.stream.Close()
 		if closeErr != nil {
 			return closeErr
@@ -415,6 +471,9 @@ func (r *Recorder) StopRecording() error {
 	r.resampleBuffer = nil
 	r.streamingMutex.Unlock()
 
+	// Deliver the queued chunks before the last one
+	r.stopDelivery()
+
 	// Resample remaining captured data
 	if len(remainingBuffer) > 0 {
 		resampled := utils.ResampleAudio(remainingBuffer, r.config.CaptureSampleRate, r.config.SampleRate)
@@ -422,6 +481,10 @@ func (r *Recorder) StopRecording() error {
 	}
 
 	if len(resampleBuffer) > 0 {
+		if r.echo != nil {
+			resampleBuffer = r.echo.Process(resampleBuffer)
+		}
+
 		// Diagnostics for the last audio chunk (debug mode only)
 		if r.enableDebug {
 			rms := utils.CalculateRMS(resampleBuffer)
@@ -496,16 +559,56 @@ func (r *Recorder) audioCallback(in []int16) {
 					rms, stats.Peak, stats.SilenceRatio*100, sampleThreshold, isSilent)
 			}
 
-			requestID := r.streamingRequestID
-			r.streamingMutex.Unlock()
+			r.enqueueChunk(capturedChunk{requestID: r.streamingRequestID, samples: chunk})
+		}
+	}
+	r.streamingMutex.Unlock()
+}
 
-			// Send asynchronously to avoid blocking recording
-			go r.handler.OnAudioChunk(requestID, chunk, false)
+// enqueueChunk hands a chunk to the delivery goroutine without blocking the
+// audio callback. A full queue drops the chunk. Must hold streamingMutex.
+func (r *Recorder) enqueueChunk(chunk capturedChunk) {
+	if r.chunkQueue == nil {
+		return
+	}
+	select {
+	case r.chunkQueue <- chunk:
+	default:
+		r.droppedChunks++
+		log.Printf("Warning: audio chunk queue full, dropped chunk (%d dropped this recording)", r.droppedChunks)
+	}
+}
 
-			r.streamingMutex.Lock()
+// deliverChunks removes the echo from queued chunks and passes them to the
+// handler in capture order until the queue is closed.
+func (r *Recorder) deliverChunks(queue <-chan capturedChunk, done chan<- struct{}) {
+	defer close(done)
+	for chunk := range queue {
+		samples := chunk.samples
+		if r.echo != nil {
+			samples = r.echo.Process(samples)
 		}
+		r.handler.OnAudioChunk(chunk.requestID, samples, false)
 	}
+}
+
+// stopDelivery detaches the chunk queue from the audio callback and waits
+// until every queued chunk has been delivered.
+func (r *Recorder) stopDelivery() {
+	r.streamingMutex.Lock()
+	queue, done, dropped := r.chunkQueue, r.chunkDone, r.droppedChunks
+	r.chunkQueue = nil
+	r.chunkDone = nil
 	r.streamingMutex.Unlock()
+
+	if queue == nil {
+		return
+	}
+	close(queue)
+	<-done
+	if dropped > 0 {
+		log.Printf("Warning: %d audio chunks dropped during recording", dropped)
+	}
 }
 
 // ConvertToWAV converts sample data to WAV format
@@ -518,6 +621,27 @@ func (r *Recorder) ConvertToWAV(samples []int16) []byte {
 	)
 }
 
+// EncodeAudio encodes samples with the configured wire codec for sending.
+// Falls back to WAV if the encoder has not been created yet.
+func (r *Recorder) EncodeAudio(samples []int16) ([]byte, error) {
+	if r.encoder == nil {
+		return r.ConvertToWAV(samples), nil
+	}
+	return r.encoder.Encode(samples)
+}
+
+// codecConfig builds the codec configuration from the audio configuration
+func codecConfig(cfg *config.AudioConfig) codec.Config {
+	return codec.Config{
+		Codec:         cfg.Codec,
+		SampleRate:    cfg.SampleRate,
+		Channels:      cfg.Channels,
+		BitDepth:      cfg.BitDepth,
+		Bitrate:       cfg.OpusBitrate,
+		FrameDuration: cfg.OpusFrameDuration,
+	}
+}
+
 // TestRecording tests recording functionality, records for a specified duration and saves to file
 func (r *Recorder) TestRecording(duration int, filename string) error {
 	// Check if already recording
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+)
+
+// redacted replaces secrets in log output
+const redacted = "REDACTED"
+
+// sensitiveParams are query parameters whose values are never logged
+var sensitiveParams = []string{"token", "access_token", "accessToken", "key", "secret", "password", "credential"}
+
+// RedactURL returns rawURL with credentials in the user info and in
+// sensitive query parameters replaced
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redacted
+	}
+
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+	}
+
+	query := u.Query()
+	changed := false
+	for _, param := range sensitiveParams {
+		if query.Has(param) {
+			query.Set(param, redacted)
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String()
+}
+
+// Redact replaces every occurrence of the given secrets in s
+func Redact(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	return s
+}
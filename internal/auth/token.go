@@ -0,0 +1,212 @@
+// Package auth provides access tokens for the chat backend
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"websocket_client_chat/internal/config"
+)
+
+// TokenProvider supplies the access token used for the WebSocket handshake
+type TokenProvider interface {
+	// Token returns a valid access token, fetching or refreshing it if needed
+	Token(ctx context.Context) (string, error)
+	// Invalidate discards any cached token, e.g. after the server rejected it
+	Invalidate()
+}
+
+// NewTokenProvider returns an HTTP provider when a token endpoint is
+// configured, a static provider when only a token is configured, and nil
+// when neither is
+func NewTokenProvider(cfg *config.WebSocketConfig, deviceID string) TokenProvider {
+	if cfg.TokenURL != "" {
+		return NewHTTPTokenProvider(cfg, deviceID)
+	}
+	if cfg.AccessToken != "" {
+		return NewStaticTokenProvider(cfg.AccessToken)
+	}
+	return nil
+}
+
+// StaticTokenProvider always returns the configured token
+type StaticTokenProvider struct {
+	token string
+}
+
+// NewStaticTokenProvider creates a static token provider
+func NewStaticTokenProvider(token string) *StaticTokenProvider {
+	return &StaticTokenProvider{token: token}
+}
+
+// Token returns the static token
+func (p *StaticTokenProvider) Token(_ context.Context) (string, error) {
+	if p.token == "" {
+		return "", fmt.Errorf("no access token configured")
+	}
+	return p.token, nil
+}
+
+// Invalidate is a no-op for a static token
+func (p *StaticTokenProvider) Invalidate() {}
+
+// HTTPTokenProvider fetches short-lived tokens from an HTTP endpoint.
+//
+// The request is a POST with the device credential as a bearer token and
+// body {"deviceId": "..."}. The response is
+// {"token": "...", "expiresIn": seconds, "credential": "..."}; a non-empty
+// credential replaces the current one (rotation) and is persisted to
+// CredentialFile so it survives restarts.
+type HTTPTokenProvider struct {
+	endpoint       string
+	deviceID       string
+	credentialFile string
+	refreshMargin  time.Duration
+	client         *http.Client
+
+	credential string
+	token      string
+	expiresAt  time.Time
+	mutex      sync.Mutex
+}
+
+// tokenResponse is the token endpoint response
+type tokenResponse struct {
+	Token      string `json:"token"`
+	ExpiresIn  int64  `json:"expiresIn"`
+	Credential string `json:"credential,omitempty"`
+}
+
+// NewHTTPTokenProvider creates an HTTP token provider. The initial
+// credential is read from CredentialFile if present, falling back to the
+// configured access token.
+func NewHTTPTokenProvider(cfg *config.WebSocketConfig, deviceID string) *HTTPTokenProvider {
+	p := &HTTPTokenProvider{
+		endpoint:       cfg.TokenURL,
+		deviceID:       deviceID,
+		credentialFile: cfg.CredentialFile,
+		refreshMargin:  cfg.TokenRefreshMargin,
+		client:         &http.Client{Timeout: cfg.WriteTimeout},
+		credential:     cfg.AccessToken,
+	}
+
+	if p.credentialFile != "" {
+		if data, err := os.ReadFile(p.credentialFile); err == nil {
+			if credential := strings.TrimSpace(string(data)); credential != "" {
+				p.credential = credential
+			}
+		}
+	}
+
+	return p
+}
+
+// SetTransport makes token requests use transport, so they go through the
+// same proxy and TLS settings as the WebSocket connection
+func (p *HTTPTokenProvider) SetTransport(transport http.RoundTripper) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.client.Transport = transport
+}
+
+// Token returns the cached token or fetches a new one when it is missing
+// or about to expire
+func (p *HTTPTokenProvider) Token(ctx context.Context) (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.token != "" && time.Now().Add(p.refreshMargin).Before(p.expiresAt) {
+		return p.token, nil
+	}
+
+	if err := p.refresh(ctx); err != nil {
+		return "", err
+	}
+	return p.token, nil
+}
+
+// Invalidate discards the cached token so the next call fetches a new one
+func (p *HTTPTokenProvider) Invalidate() {
+	p.mutex.Lock()
+	p.token = ""
+	p.expiresAt = time.Time{}
+	p.mutex.Unlock()
+}
+
+// refresh fetches a new token. Must be called with mutex held.
+func (p *HTTPTokenProvider) refresh(ctx context.Context) error {
+	body, err := json.Marshal(map[string]string{"deviceId": p.deviceID})
+	if err != nil {
+		return fmt.Errorf("json encoding failed: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build token request: %s", Redact(err.Error(), p.credential))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.credential != "" {
+		req.Header.Set("Authorization", "Bearer "+p.credential)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("token request failed: %s", Redact(err.Error(), p.credential))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token request failed: %s", resp.Status)
+	}
+
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if tr.Token == "" {
+		return fmt.Errorf("token response contained no token")
+	}
+
+	p.token = tr.Token
+	if tr.ExpiresIn > 0 {
+		p.expiresAt = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	} else {
+		// No expiry given: keep the token until the server rejects it
+		p.expiresAt = time.Now().Add(100 * 365 * 24 * time.Hour)
+	}
+
+	if tr.Credential != "" && tr.Credential != p.credential {
+		p.credential = tr.Credential
+		p.saveCredential()
+	}
+
+	log.Printf("[Auth] Obtained access token (expires in %v)", time.Until(p.expiresAt).Round(time.Second))
+	return nil
+}
+
+// saveCredential persists a rotated credential. Must be called with mutex held.
+func (p *HTTPTokenProvider) saveCredential() {
+	if p.credentialFile == "" {
+		log.Println("[Auth] Credential rotated (not persisted, no credential file configured)")
+		return
+	}
+
+	tmp := p.credentialFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(p.credential+"\n"), 0600); err != nil {
+		log.Printf("[Auth] Failed to save rotated credential: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, p.credentialFile); err != nil {
+		log.Printf("[Auth] Failed to save rotated credential: %v", err)
+		return
+	}
+	log.Printf("[Auth] Credential rotated and saved to %s", p.credentialFile)
+}
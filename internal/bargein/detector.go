@@ -0,0 +1,117 @@
+// Package bargein detects the user talking over a response on the device,
+// without waiting for the server's voice interrupt.
+package bargein
+
+import (
+	"time"
+
+	"websocket_client_chat/internal/config"
+	"websocket_client_chat/internal/session"
+	"websocket_client_chat/pkg/utils"
+)
+
+// Decision is what the caller should do with the playback
+type Decision int
+
+const (
+	None    Decision = iota // Keep the current volume
+	Duck                    // Speech started, lower the playback volume
+	Restore                 // Speech ended early, restore the volume
+	Stop                    // Speech persisted, stop the response
+)
+
+// String returns a human-readable name for the decision
+func (d Decision) String() string {
+	switch d {
+	case Duck:
+		return "duck"
+	case Restore:
+		return "restore"
+	case Stop:
+		return "stop"
+	default:
+		return "none"
+	}
+}
+
+// Detector watches captured audio while a response plays. It is not safe
+// for concurrent use.
+type Detector struct {
+	vad        *utils.VAD
+	clock      session.Clock
+	sampleRate int
+	minRMS     float64       // Fixed minimum speech level, 0 uses the caller's
+	stopAfter  time.Duration // Continuous speech that stops the response
+
+	speechSince time.Time // Start of the continuous speech, zero without speech
+	ducked      bool
+	stopped     bool // Stop was returned; nothing more until Reset
+}
+
+// NewDetector creates a detector for audio at sampleRate. A nil clock uses
+// the system clock.
+func NewDetector(cfg *config.BargeInConfig, sampleRate int, clock session.Clock) *Detector {
+	if clock == nil {
+		clock = session.SystemClock{}
+	}
+	return &Detector{
+		vad:        utils.NewVAD(sampleRate, cfg.VADAggressiveness),
+		clock:      clock,
+		sampleRate: sampleRate,
+		minRMS:     cfg.MinLevelRMS,
+		stopAfter:  cfg.StopAfter,
+	}
+}
+
+// Process classifies a captured chunk. minRMS is the speech level below
+// which the chunk does not count as speech, unless the configuration fixes
+// one. The device's own voice must already be removed or below that level.
+func (d *Detector) Process(samples []int16, minRMS float64) Decision {
+	d.vad.Process(samples)
+	if d.stopped {
+		return None
+	}
+	if d.minRMS > 0 {
+		minRMS = d.minRMS
+	}
+
+	if !d.vad.InSpeech() || utils.CalculateRMS(samples) < minRMS {
+		d.speechSince = time.Time{}
+		if d.ducked {
+			d.ducked = false
+			return Restore
+		}
+		return None
+	}
+
+	// The speech began with the chunk that just ended
+	now := d.clock.Now()
+	if d.speechSince.IsZero() {
+		d.speechSince = now.Add(-time.Duration(len(samples)) * time.Second / time.Duration(d.sampleRate))
+	}
+
+	switch {
+	case now.Sub(d.speechSince) >= d.stopAfter:
+		d.stopped = true
+		return Stop
+	case !d.ducked:
+		d.ducked = true
+		return Duck
+	default:
+		return None
+	}
+}
+
+// Ducked reports whether the playback is ducked
+func (d *Detector) Ducked() bool {
+	return d.ducked
+}
+
+// Reset forgets the current speech, e.g. when playback ends. The VAD noise
+// floor is kept.
+func (d *Detector) Reset() {
+	d.vad.Reset()
+	d.speechSince = time.Time{}
+	d.ducked = false
+	d.stopped = false
+}
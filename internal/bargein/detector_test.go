@@ -0,0 +1,210 @@
+package bargein
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"websocket_client_chat/internal/config"
+)
+
+const (
+	testSampleRate = 16000
+	testChunk      = 200 * time.Millisecond // As delivered by the recorder
+	callerMinRMS   = 200.0
+	quiet          = 5.0 // Room noise level
+)
+
+// fakeClock is a manually advanced clock
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time        { return c.now }
+func (c *fakeClock) Sleep(d time.Duration) { c.now = c.now.Add(d) }
+
+// chunk returns one chunk at the given RMS level: a voiced sound above the
+// quiet level, room noise otherwise
+func chunk(rng *rand.Rand, rms float64) []int16 {
+	n := int(testChunk.Seconds() * testSampleRate)
+	signal := make([]float64, n)
+	if rms > quiet {
+		for h := 1; h <= 15; h++ {
+			for i := range signal {
+				signal[i] += math.Sin(2*math.Pi*200*float64(h)*float64(i)/testSampleRate) / float64(h)
+			}
+		}
+	} else {
+		for i := range signal {
+			signal[i] = rng.NormFloat64()
+		}
+	}
+
+	var sum float64
+	for _, x := range signal {
+		sum += x * x
+	}
+	gain := rms / math.Sqrt(sum/float64(n))
+	out := make([]int16, n)
+	for i, x := range signal {
+		out[i] = int16(x * gain)
+	}
+	return out
+}
+
+func newTestDetector(stopAfter time.Duration, minLevel float64) (*Detector, *fakeClock) {
+	clock := newFakeClock()
+	d := NewDetector(&config.BargeInConfig{
+		VADAggressiveness: 1,
+		StopAfter:         stopAfter,
+		MinLevelRMS:       minLevel,
+	}, testSampleRate, clock)
+	return d, clock
+}
+
+// feed processes one chunk per RMS level, each arriving a chunk duration
+// after the previous one, and returns the decisions
+func feed(d *Detector, clock *fakeClock, levels []float64) []Decision {
+	rng := rand.New(rand.NewSource(1))
+	decisions := make([]Decision, len(levels))
+	for i, level := range levels {
+		clock.Sleep(testChunk)
+		decisions[i] = d.Process(chunk(rng, level), callerMinRMS)
+	}
+	return decisions
+}
+
+// repeat returns n copies of level
+func repeat(level float64, n int) []float64 {
+	levels := make([]float64, n)
+	for i := range levels {
+		levels[i] = level
+	}
+	return levels
+}
+
+func concat(parts ...[]float64) []float64 {
+	var out []float64
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestDecisions(t *testing.T) {
+	tests := []struct {
+		name     string
+		minLevel float64 // Configured minimum, 0 uses callerMinRMS
+		levels   []float64
+		want     []Decision
+	}{
+		{
+			name:   "room noise",
+			levels: repeat(quiet, 8),
+			want:   []Decision{None, None, None, None, None, None, None, None},
+		},
+		{
+			name:   "short speech ducks and restores",
+			levels: concat(repeat(quiet, 2), repeat(3000, 3), repeat(quiet, 2)),
+			want:   []Decision{None, None, Duck, None, None, Restore, None},
+		},
+		{
+			name:   "persistent speech stops",
+			levels: concat(repeat(quiet, 2), repeat(3000, 7)),
+			want:   []Decision{None, None, Duck, None, None, None, Stop, None, None},
+		},
+		{
+			name:   "nothing after stop",
+			levels: concat(repeat(quiet, 2), repeat(3000, 5), repeat(quiet, 2), repeat(3000, 2)),
+			want:   []Decision{None, None, Duck, None, None, None, Stop, None, None, None, None},
+		},
+		{
+			name:   "pause restarts the stop timer",
+			levels: concat(repeat(quiet, 2), repeat(3000, 4), repeat(quiet, 1), repeat(3000, 4)),
+			want:   []Decision{None, None, Duck, None, None, None, Restore, Duck, None, None, None},
+		},
+		{
+			name:   "speech below the caller's level",
+			levels: concat(repeat(quiet, 2), repeat(100, 6)),
+			want:   []Decision{None, None, None, None, None, None, None, None},
+		},
+		{
+			name:     "configured level overrides the caller's",
+			minLevel: 50,
+			levels:   concat(repeat(quiet, 2), repeat(100, 3), repeat(quiet, 1)),
+			want:     []Decision{None, None, Duck, None, None, Restore},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, clock := newTestDetector(time.Second, tt.minLevel)
+			got := feed(d, clock, tt.levels)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d decisions, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("decisions = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStopAfterTiming(t *testing.T) {
+	tests := []struct {
+		stopAfter time.Duration
+		delay     time.Duration // Extra delay before each speech chunk is processed
+		wantChunk int           // Speech chunk (1-based) that stops the response
+	}{
+		{400 * time.Millisecond, 0, 2},
+		{time.Second, 0, 5},
+		{1100 * time.Millisecond, 0, 6},
+		{1500 * time.Millisecond, 0, 8},
+		{time.Second, 100 * time.Millisecond, 4}, // Late chunks still count their wall time
+	}
+
+	for _, tt := range tests {
+		d, clock := newTestDetector(tt.stopAfter, 0)
+		feed(d, clock, repeat(quiet, 2))
+
+		rng := rand.New(rand.NewSource(2))
+		stoppedAt := 0
+		for i := 1; i <= 10 && stoppedAt == 0; i++ {
+			clock.Sleep(testChunk + tt.delay)
+			if d.Process(chunk(rng, 3000), callerMinRMS) == Stop {
+				stoppedAt = i
+			}
+		}
+		if stoppedAt != tt.wantChunk {
+			t.Errorf("stop after %v (delay %v): stopped at speech chunk %d, want %d",
+				tt.stopAfter, tt.delay, stoppedAt, tt.wantChunk)
+		}
+	}
+}
+
+func TestResetAfterStop(t *testing.T) {
+	d, clock := newTestDetector(400*time.Millisecond, 0)
+	feed(d, clock, concat(repeat(quiet, 2), repeat(3000, 2)))
+	if d.Process(chunk(rand.New(rand.NewSource(3)), 3000), callerMinRMS) != None {
+		t.Fatal("decision after stop")
+	}
+
+	d.Reset()
+	if d.Ducked() {
+		t.Error("still ducked after Reset")
+	}
+	got := feed(d, clock, concat(repeat(quiet, 1), repeat(3000, 2)))
+	want := []Decision{None, Duck, Stop}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("decisions after Reset = %v, want %v", got, want)
+		}
+	}
+}
@@ -12,25 +12,109 @@ import (
 
 // fileConfig holds values loaded from config.toml
 type fileConfig struct {
-	AccessToken  string `toml:"access_token"`
-	Debug        bool   `toml:"debug"`
-	WebsocketURL string `toml:"websocket_url"`
+	AccessToken    string   `toml:"access_token"`
+	TokenURL       string   `toml:"token_url"`
+	CredentialFile string   `toml:"credential_file"`
+	TokenInQuery   bool     `toml:"token_in_query"`
+	TLSCAFile      string   `toml:"tls_ca_file"`
+	TLSCertFile    string   `toml:"tls_cert_file"`
+	TLSKeyFile     string   `toml:"tls_key_file"`
+	TLSPins        []string `toml:"tls_pins"`
+	TLSMinVersion  string   `toml:"tls_min_version"`
+	ProxyURL       string   `toml:"proxy_url"`
+	ProxyUsername  string   `toml:"proxy_username"`
+	ProxyPassword  string   `toml:"proxy_password"`
+	NoProxy        []string `toml:"no_proxy"`
+	Debug          bool     `toml:"debug"`
+	WebsocketURL   string   `toml:"websocket_url"`
+	FallbackURLs   []string `toml:"fallback_websocket_urls"`
+
+	CaptureFile  string `toml:"capture_file"`
+	CaptureAudio bool   `toml:"capture_audio"`
+
+	ConversationStateFile   string        `toml:"conversation_state_file"`
+	ConversationIdleTimeout time.Duration `toml:"conversation_idle_timeout"`
+	BinaryAudio             bool          `toml:"binary_audio"`
+	GpioMode                string        `toml:"gpio_mode"`
+	GpioCancelPin           int           `toml:"gpio_cancel_pin"`
+	GpioNewConversationPin  int           `toml:"gpio_new_conversation_pin"`
+	AudioCodec              string        `toml:"audio_codec"`
+
+	EchoCancellation bool          `toml:"echo_cancellation"`
+	EchoFilterLength time.Duration `toml:"echo_filter_length"`
+	EchoMaxDelay     time.Duration `toml:"echo_max_delay"`
+
+	WakeWordTemplates []string      `toml:"wakeword_templates"`
+	WakeWordThreshold float64       `toml:"wakeword_threshold"`
+	WakeWordPreRoll   time.Duration `toml:"wakeword_preroll"`
+
+	SilenceDetector    string        `toml:"silence_detector"`
+	VADAggressiveness  int           `toml:"vad_aggressiveness"`
+	EndOfSpeechSilence time.Duration `toml:"end_of_speech_silence"`
+
+	AdaptiveSilenceThreshold bool    `toml:"adaptive_silence_threshold"`
+	SilenceMargin            float64 `toml:"silence_margin"`
+	SpeechMargin             float64 `toml:"speech_margin"`
+	SilenceThresholdMin      float64 `toml:"silence_threshold_min"`
+	SilenceThresholdMax      float64 `toml:"silence_threshold_max"`
+
+	BargeIn                  bool          `toml:"barge_in"`
+	BargeInDuckGain          float64       `toml:"barge_in_duck_gain"`
+	BargeInStopAfter         time.Duration `toml:"barge_in_stop_after"`
+	BargeInVADAggressiveness int           `toml:"barge_in_vad_aggressiveness"`
+	BargeInMinRMS            float64       `toml:"barge_in_min_rms"`
+
+	HTTPAPI    bool   `toml:"http_api"`
+	HTTPListen string `toml:"http_listen"`
+	HTTPSocket string `toml:"http_socket"`
+
+	ControlSocket string `toml:"control_socket"`
 }
 
 // loadFileConfig reads config.toml from the executable's directory or CWD.
 // If neither location has a config.toml, defaults are returned.
 func loadFileConfig() fileConfig {
 	cfg := fileConfig{
-		AccessToken:  "019cb9c7-4e91-7000-aa0b-a06b6f9b475a",
-		Debug:        false,
-		WebsocketURL: "ws://cafuuchino.studio26f.org:10580",
+		Debug:         false,
+		WebsocketURL:  "ws://cafuuchino.studio26f.org:10580",
+		AudioCodec:    "pcm",
+		TLSMinVersion: "1.2",
+		GpioMode:      GpioModeWake,
+
+		EchoFilterLength: 64 * time.Millisecond,
+		EchoMaxDelay:     500 * time.Millisecond,
+
+		WakeWordThreshold: 0.25,
+		WakeWordPreRoll:   3 * time.Second,
+
+		SilenceDetector:    SilenceDetectorVAD,
+		VADAggressiveness:  2,
+		EndOfSpeechSilence: 800 * time.Millisecond,
+
+		AdaptiveSilenceThreshold: true,
+		SilenceMargin:            2.0,
+		SpeechMargin:             4.0,
+		SilenceThresholdMin:      80,
+		SilenceThresholdMax:      1500,
+
+		BargeInDuckGain:          0.3,
+		BargeInStopAfter:         600 * time.Millisecond,
+		BargeInVADAggressiveness: 3,
+
+		HTTPListen: "127.0.0.1:8080",
+
+		ConversationStateFile:   "conversation.json",
+		ConversationIdleTimeout: 30 * time.Minute,
 	}
 
 	// Try config.toml next to the executable
+	exeDir := ""
 	if exePath, err := os.Executable(); err == nil {
-		tomlPath := filepath.Join(filepath.Dir(exePath), "config.toml")
+		exeDir = filepath.Dir(exePath)
+		tomlPath := filepath.Join(exeDir, "config.toml")
 		if _, err := toml.DecodeFile(tomlPath, &cfg); err == nil {
 			log.Printf("[Config] Loaded config from %s", tomlPath)
+			cfg.resolvePaths(exeDir)
 			return cfg
 		}
 	}
@@ -38,22 +122,46 @@ func loadFileConfig() fileConfig {
 	// Try config.toml in the current working directory
 	if _, err := toml.DecodeFile("config.toml", &cfg); err == nil {
 		log.Printf("[Config] Loaded config from config.toml (CWD)")
+		if cwd, err := os.Getwd(); err == nil {
+			cfg.resolvePaths(cwd)
+		}
 		return cfg
 	}
 
-	log.Println("[Config] No config.toml found, using defaults")
+	log.Println("[Config] No config.toml found, using defaults (no access token configured)")
+	cfg.resolvePaths(exeDir)
 	return cfg
 }
 
+// resolvePaths makes the relative state file path relative to dir, the
+// config file's directory, instead of the working directory, which is
+// usually / under a service manager. Without dir the state is kept in
+// memory only.
+func (cfg *fileConfig) resolvePaths(dir string) {
+	if cfg.ConversationStateFile == "" || filepath.IsAbs(cfg.ConversationStateFile) {
+		return
+	}
+	if dir == "" {
+		log.Printf("[Config] Cannot resolve conversation_state_file %q, keeping the conversation in memory only", cfg.ConversationStateFile)
+		cfg.ConversationStateFile = ""
+		return
+	}
+	cfg.ConversationStateFile = filepath.Join(dir, cfg.ConversationStateFile)
+}
+
 // Config is the application configuration
 type Config struct {
-	Audio       AudioConfig     `json:"audio"`
-	WebSocket   WebSocketConfig `json:"websocket"`
-	Control     ControlConfig   `json:"control"`
-	Gpio        GpioConfig      `json:"gpio"`
-	Wake        WakeConfig      `json:"wake"`
-	Device      DeviceConfig    `json:"device"`
-	EnableDebug bool            `json:"enableDebug"` // Global debug switch
+	Audio        AudioConfig        `json:"audio"`
+	WebSocket    WebSocketConfig    `json:"websocket"`
+	Control      ControlConfig      `json:"control"`
+	Gpio         GpioConfig         `json:"gpio"`
+	Wake         WakeConfig         `json:"wake"`
+	WakeWord     WakeWordConfig     `json:"wakeWord"`
+	BargeIn      BargeInConfig      `json:"bargeIn"`
+	HTTP         HTTPConfig         `json:"http"`
+	Device       DeviceConfig       `json:"device"`
+	Conversation ConversationConfig `json:"conversation"`
+	EnableDebug  bool               `json:"enableDebug"` // Global debug switch
 }
 
 // AudioConfig is the audio configuration
@@ -66,31 +174,124 @@ type AudioConfig struct {
 	ChunkDuration     time.Duration `json:"chunkDuration"`     // Audio chunk duration
 	ChunkSampleCount  int           `json:"chunkSampleCount"`  // Samples per chunk (output)
 	ChunkByteSize     int           `json:"chunkByteSize"`     // Bytes per chunk (output)
+
+	Codec             string        `json:"codec"`             // Wire codec: "pcm" (WAV, default) or "opus"
+	OpusBitrate       int           `json:"opusBitrate"`       // Opus target bitrate in bits/s
+	OpusFrameDuration time.Duration `json:"opusFrameDuration"` // Opus frame duration
+
+	EchoCancellation bool          `json:"echoCancellation"` // Subtract the playback echo from captured audio
+	EchoFilterLength time.Duration `json:"echoFilterLength"` // Echo tail modelled by the adaptive filter
+	EchoMaxDelay     time.Duration `json:"echoMaxDelay"`     // Largest playback-to-capture delay searched
 }
 
 // WebSocketConfig is the WebSocket configuration
 type WebSocketConfig struct {
-	URL               string        `json:"url"`
+	URL               string        `json:"url"`               // Primary endpoint URL, never contains credentials
+	FallbackURLs      []string      `json:"fallbackUrls"`      // Further endpoints in priority order
 	ReconnectDelay    time.Duration `json:"reconnectDelay"`    // Initial reconnect delay (base for exponential backoff)
 	MaxReconnectDelay time.Duration `json:"maxReconnectDelay"` // Maximum reconnect delay cap
+	ReconnectJitter   float64       `json:"reconnectJitter"`   // Fraction of each delay randomized (0-1)
+	BreakerThreshold  int           `json:"breakerThreshold"`  // Consecutive failures before an endpoint is parked (0 disables)
+	BreakerCooldown   time.Duration `json:"breakerCooldown"`   // How long a failing endpoint stays parked
 	PingInterval      time.Duration `json:"pingInterval"`
 	WriteTimeout      time.Duration `json:"writeTimeout"`
 	ReadTimeout       time.Duration `json:"readTimeout"`
 	MaxMessageSize    int64         `json:"maxMessageSize"`
+
+	SendQueueSize      int    `json:"sendQueueSize"`      // Max queued uplink audio messages
+	SendOverflowPolicy string `json:"sendOverflowPolicy"` // "dropOldest" or "coalesce" when the audio queue is full
+	BinaryAudio        bool   `json:"binaryAudio"`        // Offer binary PCM frames instead of base64 JSON audio
+
+	OfflineBufferBytes  int           `json:"offlineBufferBytes"`  // Max audio bytes held while disconnected (0 disables)
+	OfflineBufferMaxAge time.Duration `json:"offlineBufferMaxAge"` // Buffered turns older than this are discarded
+
+	AccessToken        string        `json:"-"`                  // Static token, or device credential when TokenURL is set
+	TokenURL           string        `json:"tokenUrl"`           // HTTP endpoint issuing short-lived tokens (optional)
+	CredentialFile     string        `json:"credentialFile"`     // Where a rotated device credential is persisted
+	TokenRefreshMargin time.Duration `json:"tokenRefreshMargin"` // Refresh tokens this long before they expire
+	TokenInQuery       bool          `json:"tokenInQuery"`       // Also send the token as ?token= for legacy backends
+
+	TLS   TLSConfig   `json:"tls"`   // Only used for wss:// URLs
+	Proxy ProxyConfig `json:"proxy"` // Outbound proxy for the connection
+
+	Capture CaptureConfig `json:"capture"` // Protocol traffic capture
+
+	RequestTimeout  time.Duration `json:"requestTimeout"`  // Deadline for each attempt of an acknowledged request
+	RequestAttempts int           `json:"requestAttempts"` // Total attempts for an acknowledged request
+	RequestBackoff  time.Duration `json:"requestBackoff"`  // Delay before retrying, doubled per attempt
+}
+
+// TLSConfig is the TLS configuration for wss:// connections
+type TLSConfig struct {
+	CAFile     string   `json:"caFile"`     // PEM CA bundle replacing the system roots (optional)
+	CertFile   string   `json:"certFile"`   // PEM client certificate (optional, requires KeyFile)
+	KeyFile    string   `json:"keyFile"`    // PEM client private key
+	Pins       []string `json:"pins"`       // Base64 SHA-256 SPKI hashes; one must match the server chain
+	MinVersion string   `json:"minVersion"` // "1.2" or "1.3"
+}
+
+// ProxyConfig is the outbound proxy configuration. When URL is empty the
+// HTTPS_PROXY / HTTP_PROXY / ALL_PROXY and NO_PROXY environment variables
+// are used.
+type ProxyConfig struct {
+	URL      string   `json:"url"`      // http://host:port (CONNECT) or socks5://host:port
+	Username string   `json:"username"` // Overrides credentials in the URL
+	Password string   `json:"-"`
+	NoProxy  []string `json:"noProxy"` // Hosts, domain suffixes, IPs or CIDRs reached directly
+}
+
+// Enabled reports whether any TLS option is set
+func (t *TLSConfig) Enabled() bool {
+	return t.CAFile != "" || t.CertFile != "" || t.KeyFile != "" || len(t.Pins) > 0
+}
+
+// CaptureConfig is the protocol capture configuration
+type CaptureConfig struct {
+	Path         string `json:"path"`         // JSONL capture file ("" disables capture)
+	MaxBytes     int64  `json:"maxBytes"`     // Rotate when the file would exceed this size (0 never rotates)
+	MaxFiles     int    `json:"maxFiles"`     // Rotated files kept as path.1 … path.N
+	IncludeAudio bool   `json:"includeAudio"` // Store audio payloads instead of only their size and hash
+}
+
+// EndpointURLs returns the primary URL followed by the fallbacks
+func (w *WebSocketConfig) EndpointURLs() []string {
+	urls := make([]string, 0, 1+len(w.FallbackURLs))
+	if w.URL != "" {
+		urls = append(urls, w.URL)
+	}
+	return append(urls, w.FallbackURLs...)
 }
 
 // ControlConfig is the control configuration
 type ControlConfig struct {
 	FilePath     string        `json:"filePath"`
 	MonitorDelay time.Duration `json:"monitorDelay"`
+	SocketPath   string        `json:"socketPath"` // JSON-RPC control socket, in every mode ("" disables)
 }
 
+// GPIO button modes
+const (
+	GpioModeWake       = "wake" // Falling edge wakes a session that ends on silence
+	GpioModePushToTalk = "ptt"  // Press streams audio, release ends the utterance
+)
+
 // GpioConfig is the GPIO configuration
 type GpioConfig struct {
 	PinNumber    int           `json:"pinNumber"`    // GPIO pin number (e.g. 200 = PG8)
 	PollInterval time.Duration `json:"pollInterval"` // Polling interval for GPIO value
+	Mode         string        `json:"mode"`         // GpioModeWake or GpioModePushToTalk
+
+	// Optional buttons running a command when pressed (0 disables)
+	CancelPin          int `json:"cancelPin"`          // Cancel the current answer
+	NewConversationPin int `json:"newConversationPin"` // Start a new conversation
 }
 
+// Silence detectors ending an ACTIVE turn
+const (
+	SilenceDetectorVAD = "vad" // Frame-level voice activity detection
+	SilenceDetectorRMS = "rms" // Whole-buffer RMS check every SilenceCheckInterval
+)
+
 // WakeConfig is the wake/sleep state configuration
 type WakeConfig struct {
 	BufferDuration       time.Duration `json:"bufferDuration"`       // Circular wake buffer duration (e.g. 8s)
@@ -99,6 +300,44 @@ type WakeConfig struct {
 	SilenceRatio         float64       `json:"silenceRatio"`         // Ratio of silent samples to consider as silence
 	SilenceBufferSeconds int           `json:"silenceBufferSeconds"` // Seconds of audio to keep for silence checking
 	CancelCooldown       time.Duration `json:"cancelCooldown"`       // Cooldown after sending cancelOutput before starting a new session
+	SilenceDetector      string        `json:"silenceDetector"`      // SilenceDetectorVAD or SilenceDetectorRMS
+	VADAggressiveness    int           `json:"vadAggressiveness"`    // 0 (lenient) to 3 (strict)
+	EndOfSpeechSilence   time.Duration `json:"endOfSpeechSilence"`   // Silence after speech that ends the turn (VAD)
+
+	// Thresholds relative to the ambient noise measured while SLEEPING.
+	// SilenceThresholdRMS is used until enough audio was measured.
+	AdaptiveThreshold bool          `json:"adaptiveThreshold"`
+	SilenceMargin     float64       `json:"silenceMargin"`   // Silence threshold = noise floor × margin
+	SpeechMargin      float64       `json:"speechMargin"`    // Minimum speech level = noise floor × margin
+	MinThresholdRMS   float64       `json:"minThresholdRms"` // Lower bound of the derived thresholds
+	MaxThresholdRMS   float64       `json:"maxThresholdRms"` // Upper bound of the derived thresholds
+	NoiseWindow       time.Duration `json:"noiseWindow"`     // Audio the noise floor is estimated over
+}
+
+// WakeWordConfig is the on-device wake word configuration (-mode wakeword)
+type WakeWordConfig struct {
+	Engine     string        `json:"engine"`     // Keyword spotter implementation ("template")
+	Templates  []string      `json:"templates"`  // Enrolled keyword samples (16-bit PCM WAV files)
+	Threshold  float64       `json:"threshold"`  // Maximum match distance (0-2, lower is stricter)
+	PreRoll    time.Duration `json:"preRoll"`    // Audio before the detection sent as wake audio
+	Refractory time.Duration `json:"refractory"` // Minimum time between detections
+}
+
+// BargeInConfig is the on-device barge-in configuration. The detector
+// watches captured audio while a response plays.
+type BargeInConfig struct {
+	Enabled           bool          `json:"enabled"`
+	DuckGain          float64       `json:"duckGain"`          // Playback gain (0-1) while the user talks
+	StopAfter         time.Duration `json:"stopAfter"`         // Continuous speech that stops the response
+	VADAggressiveness int           `json:"vadAggressiveness"` // 0 (lenient) to 3 (strict)
+	MinLevelRMS       float64       `json:"minLevelRms"`       // Minimum speech level, 0 uses the adaptive speech threshold
+}
+
+// HTTPConfig is the local HTTP control API configuration
+type HTTPConfig struct {
+	Enabled    bool   `json:"enabled"`    // Serve the API in every control mode (always on with -mode http)
+	Address    string `json:"address"`    // TCP listen address, loopback by default
+	SocketPath string `json:"socketPath"` // Unix socket to listen on instead of Address
 }
 
 // DeviceConfig is the device configuration
@@ -109,6 +348,13 @@ type DeviceConfig struct {
 	OutputText   bool     `json:"outputText"`
 	Location     Location `json:"location"`
 	Timezone     string   `json:"timezone,omitempty"` // Timezone, e.g. "Asia/Shanghai"
+	AudioCodec   string   `json:"audioCodec"`         // Codec advertised in updateConfig, mirrors Audio.Codec
+}
+
+// ConversationConfig is the conversation continuity configuration
+type ConversationConfig struct {
+	StateFile   string        `json:"stateFile"`   // Where the active conversation ID is persisted ("" keeps it in memory only)
+	IdleTimeout time.Duration `json:"idleTimeout"` // Start a new conversation after this long without activity (0 never expires)
 }
 
 // Location is the location information
@@ -133,10 +379,27 @@ func DefaultConfig() *Config {
 
 	// Read configs from config.toml
 	fileCfg := loadFileConfig()
-	accessToken := fileCfg.AccessToken
 	enableDebug := fileCfg.Debug
 	websocketHost := fileCfg.WebsocketURL
 
+	gpioMode := fileCfg.GpioMode
+	if gpioMode != GpioModeWake && gpioMode != GpioModePushToTalk {
+		log.Printf("[Config] Unknown gpio_mode %q, using %q", gpioMode, GpioModeWake)
+		gpioMode = GpioModeWake
+	}
+
+	silenceDetector := fileCfg.SilenceDetector
+	if silenceDetector != SilenceDetectorVAD && silenceDetector != SilenceDetectorRMS {
+		log.Printf("[Config] Unknown silence_detector %q, using %q", silenceDetector, SilenceDetectorVAD)
+		silenceDetector = SilenceDetectorVAD
+	}
+
+	// Fallback hosts use the same endpoint path as the primary
+	fallbackURLs := make([]string, 0, len(fileCfg.FallbackURLs))
+	for _, host := range fileCfg.FallbackURLs {
+		fallbackURLs = append(fallbackURLs, fmt.Sprintf("%s/api/v1/chat/ws", host))
+	}
+
 	return &Config{
 		EnableDebug: enableDebug, // Global debug switch
 		Audio: AudioConfig{
@@ -148,23 +411,77 @@ func DefaultConfig() *Config {
 			ChunkDuration:     chunkDuration,
 			ChunkSampleCount:  chunkSampleCount,
 			ChunkByteSize:     chunkByteSize,
+			Codec:             fileCfg.AudioCodec,
+			OpusBitrate:       16000,
+			OpusFrameDuration: 20 * time.Millisecond,
+			EchoCancellation:  fileCfg.EchoCancellation,
+			EchoFilterLength:  fileCfg.EchoFilterLength,
+			EchoMaxDelay:      fileCfg.EchoMaxDelay,
 		},
 		WebSocket: WebSocketConfig{
-			URL:               fmt.Sprintf("%s/api/v1/chat/ws?token=%s", websocketHost, accessToken),
+			URL:               fmt.Sprintf("%s/api/v1/chat/ws", websocketHost),
+			FallbackURLs:      fallbackURLs,
 			ReconnectDelay:    5 * time.Second,
 			MaxReconnectDelay: 160 * time.Second,
+			ReconnectJitter:   0.5,
+			BreakerThreshold:  3,
+			BreakerCooldown:   60 * time.Second,
 			PingInterval:      30 * time.Second,
 			WriteTimeout:      10 * time.Second,
 			ReadTimeout:       60 * time.Second,
 			MaxMessageSize:    1024 * 1024, // 1MB
+
+			SendQueueSize:      50, // 10s of 200ms chunks
+			SendOverflowPolicy: "dropOldest",
+			BinaryAudio:        fileCfg.BinaryAudio,
+
+			OfflineBufferBytes:  320 * 1024, // ~10s of 16 kHz 16-bit mono WAV
+			OfflineBufferMaxAge: 15 * time.Second,
+
+			AccessToken:        fileCfg.AccessToken,
+			TokenURL:           fileCfg.TokenURL,
+			CredentialFile:     fileCfg.CredentialFile,
+			TokenRefreshMargin: 30 * time.Second,
+			TokenInQuery:       fileCfg.TokenInQuery,
+
+			TLS: TLSConfig{
+				CAFile:     fileCfg.TLSCAFile,
+				CertFile:   fileCfg.TLSCertFile,
+				KeyFile:    fileCfg.TLSKeyFile,
+				Pins:       fileCfg.TLSPins,
+				MinVersion: fileCfg.TLSMinVersion,
+			},
+
+			Proxy: ProxyConfig{
+				URL:      fileCfg.ProxyURL,
+				Username: fileCfg.ProxyUsername,
+				Password: fileCfg.ProxyPassword,
+				NoProxy:  fileCfg.NoProxy,
+			},
+
+			Capture: CaptureConfig{
+				Path:         fileCfg.CaptureFile,
+				MaxBytes:     10 * 1024 * 1024,
+				MaxFiles:     5,
+				IncludeAudio: fileCfg.CaptureAudio,
+			},
+
+			RequestTimeout:  5 * time.Second,
+			RequestAttempts: 3,
+			RequestBackoff:  500 * time.Millisecond,
 		},
 		Control: ControlConfig{
 			FilePath:     "/tmp/chat-control",
 			MonitorDelay: 100 * time.Millisecond,
+			SocketPath:   fileCfg.ControlSocket,
 		},
 		Gpio: GpioConfig{
 			PinNumber:    200,
 			PollInterval: 100 * time.Millisecond,
+			Mode:         gpioMode,
+
+			CancelPin:          fileCfg.GpioCancelPin,
+			NewConversationPin: fileCfg.GpioNewConversationPin,
 		},
 		Wake: WakeConfig{
 			BufferDuration:       8 * time.Second,
@@ -173,6 +490,34 @@ func DefaultConfig() *Config {
 			SilenceRatio:         0.95,
 			SilenceBufferSeconds: 3,
 			CancelCooldown:       300 * time.Millisecond,
+			SilenceDetector:      silenceDetector,
+			VADAggressiveness:    fileCfg.VADAggressiveness,
+			EndOfSpeechSilence:   fileCfg.EndOfSpeechSilence,
+			AdaptiveThreshold:    fileCfg.AdaptiveSilenceThreshold,
+			SilenceMargin:        fileCfg.SilenceMargin,
+			SpeechMargin:         fileCfg.SpeechMargin,
+			MinThresholdRMS:      fileCfg.SilenceThresholdMin,
+			MaxThresholdRMS:      fileCfg.SilenceThresholdMax,
+			NoiseWindow:          10 * time.Second,
+		},
+		WakeWord: WakeWordConfig{
+			Engine:     "template",
+			Templates:  fileCfg.WakeWordTemplates,
+			Threshold:  fileCfg.WakeWordThreshold,
+			PreRoll:    fileCfg.WakeWordPreRoll,
+			Refractory: 2 * time.Second,
+		},
+		BargeIn: BargeInConfig{
+			Enabled:           fileCfg.BargeIn,
+			DuckGain:          fileCfg.BargeInDuckGain,
+			StopAfter:         fileCfg.BargeInStopAfter,
+			VADAggressiveness: fileCfg.BargeInVADAggressiveness,
+			MinLevelRMS:       fileCfg.BargeInMinRMS,
+		},
+		HTTP: HTTPConfig{
+			Enabled:    fileCfg.HTTPAPI,
+			Address:    fileCfg.HTTPListen,
+			SocketPath: fileCfg.HTTPSocket,
 		},
 		Device: DeviceConfig{
 			SerialNumber: "DEV-001",
@@ -183,7 +528,12 @@ func DefaultConfig() *Config {
 				Latitude:  0,
 				Longitude: 0,
 			},
-			Timezone: "Asia/Shanghai", // Default timezone
+			Timezone:   "Asia/Shanghai", // Default timezone
+			AudioCodec: fileCfg.AudioCodec,
+		},
+		Conversation: ConversationConfig{
+			StateFile:   fileCfg.ConversationStateFile,
+			IdleTimeout: fileCfg.ConversationIdleTimeout,
 		},
 	}
 }
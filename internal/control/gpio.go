@@ -9,17 +9,26 @@ import (
 	"time"
 
 	"websocket_client_chat/internal/config"
+	"websocket_client_chat/internal/events"
 )
 
 // GpioHandler is the GPIO event handler interface
 type GpioHandler interface {
-	OnGpioWake()
+	OnGpioWake()    // Falling edge in wake mode
+	OnGpioPress()   // Button pressed in push-to-talk mode
+	OnGpioRelease() // Button released in push-to-talk mode
+	// OnGpioCommand is called when a command button is pressed. It must not
+	// block, since the pins are polled on the calling goroutine.
+	OnGpioCommand(cmd Command)
 }
 
-// GpioMonitor monitors a GPIO pin via sysfs for wake events
+// GpioMonitor monitors a GPIO pin via sysfs for wake events, and the
+// optional command buttons
 type GpioMonitor struct {
-	config  *config.GpioConfig
-	handler GpioHandler
+	config   *config.GpioConfig
+	handler  GpioHandler
+	events   *events.Bus     // Receives the edges detected (optional)
+	commands map[int]Command // Command buttons by pin
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -29,22 +38,53 @@ type GpioMonitor struct {
 func NewGpioMonitor(parentCtx context.Context, cfg *config.GpioConfig, handler GpioHandler) *GpioMonitor {
 	ctx, cancel := context.WithCancel(parentCtx)
 
+	commands := make(map[int]Command)
+	if cfg.CancelPin > 0 {
+		commands[cfg.CancelPin] = CmdCancelOutput
+	}
+	if cfg.NewConversationPin > 0 {
+		commands[cfg.NewConversationPin] = CmdNewConversation
+	}
+
 	return &GpioMonitor{
-		config:  cfg,
-		handler: handler,
-		ctx:     ctx,
-		cancel:  cancel,
+		config:   cfg,
+		handler:  handler,
+		commands: commands,
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 
-// Start initializes the GPIO pin and starts monitoring
+// SetEventBus publishes the edges detected to bus. Must be called before Start.
+func (gm *GpioMonitor) SetEventBus(bus *events.Bus) {
+	gm.events = bus
+}
+
+// publishEdge publishes a detected edge to the event bus
+func (gm *GpioMonitor) publishEdge(pin int, edge string) {
+	gm.events.Publish(events.TypeGpio, events.SourceGpio, events.Gpio{Pin: pin, Edge: edge})
+}
+
+// Start initializes the GPIO pins and starts monitoring
 func (gm *GpioMonitor) Start() error {
-	if err := gm.initGpio(); err != nil {
+	if err := gm.initGpio(gm.config.PinNumber); err != nil {
 		return fmt.Errorf("failed to initialize GPIO %d: %w", gm.config.PinNumber, err)
 	}
+	for pin := range gm.commands {
+		if pin == gm.config.PinNumber {
+			return fmt.Errorf("GPIO %d is both the session button and a command button", pin)
+		}
+		if err := gm.initGpio(pin); err != nil {
+			return fmt.Errorf("failed to initialize GPIO %d: %w", pin, err)
+		}
+	}
 
 	go gm.monitorLoop()
-	log.Printf("GPIO monitor started on pin %d (poll interval: %v)", gm.config.PinNumber, gm.config.PollInterval)
+	log.Printf("GPIO monitor started on pin %d (mode: %s, poll interval: %v)",
+		gm.config.PinNumber, gm.config.Mode, gm.config.PollInterval)
+	for pin, cmd := range gm.commands {
+		log.Printf("GPIO command button on pin %d (command %s)", pin, cmd)
+	}
 	return nil
 }
 
@@ -54,16 +94,16 @@ func (gm *GpioMonitor) Stop() error {
 	return nil
 }
 
-// initGpio exports the GPIO pin and sets direction to input
-func (gm *GpioMonitor) initGpio() error {
-	pinStr := fmt.Sprintf("%d", gm.config.PinNumber)
-	gpioDir := fmt.Sprintf("/sys/class/gpio/gpio%d", gm.config.PinNumber)
+// initGpio exports a GPIO pin and sets direction to input
+func (gm *GpioMonitor) initGpio(pin int) error {
+	pinStr := fmt.Sprintf("%d", pin)
+	gpioDir := fmt.Sprintf("/sys/class/gpio/gpio%d", pin)
 
 	// Check if already exported
 	if _, err := os.Stat(gpioDir); os.IsNotExist(err) {
 		// Export the GPIO pin
 		if err := os.WriteFile("/sys/class/gpio/export", []byte(pinStr), 0644); err != nil {
-			return fmt.Errorf("failed to export GPIO %d: %w", gm.config.PinNumber, err)
+			return fmt.Errorf("failed to export GPIO %d: %w", pin, err)
 		}
 		// Give sysfs a moment to create the directory
 		time.Sleep(50 * time.Millisecond)
@@ -72,15 +112,15 @@ func (gm *GpioMonitor) initGpio() error {
 	// Set direction to input
 	directionPath := fmt.Sprintf("%s/direction", gpioDir)
 	if err := os.WriteFile(directionPath, []byte("in"), 0644); err != nil {
-		return fmt.Errorf("failed to set GPIO %d direction: %w", gm.config.PinNumber, err)
+		return fmt.Errorf("failed to set GPIO %d direction: %w", pin, err)
 	}
 
 	return nil
 }
 
-// readGpioValue reads the current GPIO pin value (0 or 1)
-func (gm *GpioMonitor) readGpioValue() (int, error) {
-	valuePath := fmt.Sprintf("/sys/class/gpio/gpio%d/value", gm.config.PinNumber)
+// readGpioValue reads the current value of a GPIO pin (0 or 1)
+func (gm *GpioMonitor) readGpioValue(pin int) (int, error) {
+	valuePath := fmt.Sprintf("/sys/class/gpio/gpio%d/value", pin)
 	data, err := os.ReadFile(valuePath)
 	if err != nil {
 		return -1, err
@@ -93,24 +133,36 @@ func (gm *GpioMonitor) readGpioValue() (int, error) {
 	return 1, nil
 }
 
-// monitorLoop polls the GPIO pin for falling edge (high -> low transition)
+// monitorLoop polls the GPIO pin for edges. The button pulls the pin low:
+// in wake mode only the falling edge (high -> low) is reported, in
+// push-to-talk mode both the press and the release. Command buttons run
+// their command on the falling edge.
 func (gm *GpioMonitor) monitorLoop() {
 	ticker := time.NewTicker(gm.config.PollInterval)
 	defer ticker.Stop()
 
 	// Read initial state
-	prevState, err := gm.readGpioValue()
+	prevState, err := gm.readGpioValue(gm.config.PinNumber)
 	if err != nil {
 		log.Printf("Failed to read initial GPIO state: %v", err)
 		prevState = 1 // Assume high (not pressed)
 	}
+	commandStates := make(map[int]int, len(gm.commands))
+	for pin := range gm.commands {
+		commandStates[pin] = 1
+		if state, err := gm.readGpioValue(pin); err == nil {
+			commandStates[pin] = state
+		}
+	}
 
 	for {
 		select {
 		case <-gm.ctx.Done():
 			return
 		case <-ticker.C:
-			currentState, err := gm.readGpioValue()
+			gm.pollCommands(commandStates)
+
+			currentState, err := gm.readGpioValue(gm.config.PinNumber)
 			if err != nil {
 				log.Printf("Failed to read GPIO value: %v", err)
 				continue
@@ -118,11 +170,43 @@ func (gm *GpioMonitor) monitorLoop() {
 
 			// Detect falling edge: high (1) -> low (0)
 			if prevState == 1 && currentState == 0 {
-				log.Println("GPIO wake trigger detected (falling edge)")
-				gm.handler.OnGpioWake()
+				if gm.config.Mode == config.GpioModePushToTalk {
+					log.Println("GPIO push-to-talk pressed (falling edge)")
+					gm.publishEdge(gm.config.PinNumber, "press")
+					gm.handler.OnGpioPress()
+				} else {
+					log.Println("GPIO wake trigger detected (falling edge)")
+					gm.publishEdge(gm.config.PinNumber, "wake")
+					gm.handler.OnGpioWake()
+				}
+			}
+
+			// Detect rising edge: low (0) -> high (1)
+			if prevState == 0 && currentState == 1 && gm.config.Mode == config.GpioModePushToTalk {
+				log.Println("GPIO push-to-talk released (rising edge)")
+				gm.publishEdge(gm.config.PinNumber, "release")
+				gm.handler.OnGpioRelease()
 			}
 
 			prevState = currentState
 		}
 	}
 }
+
+// pollCommands runs the command of every command button pressed since the
+// last poll
+func (gm *GpioMonitor) pollCommands(states map[int]int) {
+	for pin, cmd := range gm.commands {
+		state, err := gm.readGpioValue(pin)
+		if err != nil {
+			log.Printf("Failed to read GPIO %d value: %v", pin, err)
+			continue
+		}
+		if states[pin] == 1 && state == 0 {
+			log.Printf("GPIO command button %d pressed (command %s)", pin, cmd)
+			gm.publishEdge(pin, "press")
+			gm.handler.OnGpioCommand(cmd)
+		}
+		states[pin] = state
+	}
+}
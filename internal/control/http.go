@@ -0,0 +1,371 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"websocket_client_chat/internal/config"
+	"websocket_client_chat/internal/events"
+)
+
+// ErrUnavailable is returned by an APIHandler for a request that is valid
+// but cannot be carried out in the current mode or state
+var ErrUnavailable = errors.New("not available")
+
+// APIHandler is the application behind the HTTP API and the control socket
+type APIHandler interface {
+	// ExecuteCommand runs a command; the error says why it was refused
+	ExecuteCommand(cmd Command) error
+	// ExecuteCommandWait runs a command and waits for its outcome, e.g. the
+	// server's acknowledgment; the error also reports a failed command
+	ExecuteCommandWait(cmd Command) error
+	// Wake starts a session as the GPIO button or wake word would
+	Wake(source string) error
+	SetOutputVolume(volume float64)
+	OutputVolume() float64
+	// StatusReport and ConfigReport return the JSON bodies of GET /status and GET /config
+	StatusReport() any
+	ConfigReport() any
+	// UpdateDevice changes the device settings sent with the next session
+	UpdateDevice(update DeviceUpdate) error
+}
+
+// DeviceUpdate holds the device settings changed by PUT /config; nil fields
+// are left unchanged
+type DeviceUpdate struct {
+	VoiceID    *string `json:"voiceId"`
+	SpeechRate *int    `json:"speechRate"`
+}
+
+// sseHeartbeat keeps idle event streams open through proxies
+const sseHeartbeat = 15 * time.Second
+
+// apiCommands maps POST endpoints to commands
+var apiCommands = map[string]Command{
+	"/recording/start":  CmdStartRecording,
+	"/recording/stop":   CmdStopRecording,
+	"/recording/test":   CmdTestRecording,
+	"/cancel":           CmdCancelOutput,
+	"/context/clear":    CmdClearContext,
+	"/conversation/new": CmdNewConversation,
+}
+
+// HTTPServer serves the local control and status API
+type HTTPServer struct {
+	config  *config.HTTPConfig
+	handler APIHandler
+	events  *events.Bus // Streamed by GET /events and receives the commands (optional)
+	server  *http.Server
+	network bool // Listening on a non-loopback address
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewHTTPServer creates a new HTTP API server
+func NewHTTPServer(parentCtx context.Context, cfg *config.HTTPConfig, handler APIHandler) *HTTPServer {
+	ctx, cancel := context.WithCancel(parentCtx)
+
+	hs := &HTTPServer{
+		config:  cfg,
+		handler: handler,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	mux := http.NewServeMux()
+	for path, cmd := range apiCommands {
+		mux.HandleFunc(path, hs.commandHandler(cmd))
+	}
+	mux.HandleFunc("/wake", hs.handleWake)
+	mux.HandleFunc("/volume", hs.handleVolume)
+	mux.HandleFunc("/config", hs.handleConfig)
+	mux.HandleFunc("/status", hs.handleStatus)
+	mux.HandleFunc("/events", hs.handleEvents)
+
+	hs.server = &http.Server{
+		Handler:           hs.guard(mux),
+		ReadHeaderTimeout: 5 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	return hs
+}
+
+// SetEventBus streams bus on GET /events and publishes the commands
+// received to it. Must be called before Start.
+func (hs *HTTPServer) SetEventBus(bus *events.Bus) {
+	hs.events = bus
+}
+
+// Start listens on the Unix socket, or the TCP address if no socket is
+// configured, and serves requests in the background
+func (hs *HTTPServer) Start() error {
+	listener, err := hs.listen()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := hs.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP API stopped: %v", err)
+		}
+	}()
+	log.Printf("HTTP API listening on %s", listener.Addr())
+	return nil
+}
+
+// Stop closes the event streams and shuts the server down
+func (hs *HTTPServer) Stop() error {
+	hs.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err := hs.server.Shutdown(ctx)
+	if hs.config.SocketPath != "" {
+		os.Remove(hs.config.SocketPath)
+	}
+	return err
+}
+
+// listen opens the configured listener
+func (hs *HTTPServer) listen() (net.Listener, error) {
+	if hs.config.SocketPath != "" {
+		return listenUnix(hs.config.SocketPath)
+	}
+
+	listener, err := net.Listen("tcp", hs.config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", hs.config.Address, err)
+	}
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok && !addr.IP.IsLoopback() {
+		log.Printf("Warning: HTTP API on %s is reachable from the network without authentication", addr)
+		hs.network = true
+	}
+	return listener, nil
+}
+
+// listenUnix listens on a Unix socket that the owner and group can use
+func listenUnix(path string) (net.Listener, error) {
+	// A socket file left behind by an earlier run blocks the bind
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set permissions of %s: %w", path, err)
+	}
+	return listener, nil
+}
+
+// guard rejects requests a web page could have made. Browsers send an Origin
+// header with cross-site requests, DNS rebinding shows up as a foreign host
+// name, and a page cannot send a JSON content type without a CORS preflight.
+func (hs *HTTPServer) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "cross-origin requests are not allowed"})
+			return
+		}
+		if hs.config.SocketPath == "" && !hs.allowedHost(r.Host) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "host not allowed"})
+			return
+		}
+		if r.Method == http.MethodPost || r.Method == http.MethodPut {
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+				writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "Content-Type must be application/json"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost reports whether the Host header names a loopback address. On a
+// network address any IP literal is accepted, since rebinding needs a name.
+func (hs *HTTPServer) allowedHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || hs.network)
+}
+
+// commandHandler returns the handler of a command endpoint
+func (hs *HTTPServer) commandHandler(cmd Command) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		log.Printf("HTTP command: %s %s", r.Method, r.URL.Path)
+		publishCommand(hs.events, events.SourceHTTP, cmd)
+		if err := hs.handler.ExecuteCommand(cmd); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"command": string(cmd)})
+	}
+}
+
+// handleWake starts a session (gpio and wakeword modes)
+func (hs *HTTPServer) handleWake(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	if err := hs.handler.Wake("HTTP"); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{})
+}
+
+// handleVolume reads or sets the output volume
+func (hs *HTTPServer) handleVolume(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	if r.Method == http.MethodPut {
+		var body struct {
+			Volume *float64 `json:"volume"`
+		}
+		if err := decodeBody(r, &body); err != nil || body.Volume == nil || *body.Volume < 0 || *body.Volume > 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected {\"volume\": 0-1}"})
+			return
+		}
+		hs.handler.SetOutputVolume(*body.Volume)
+		log.Printf("HTTP API: output volume set to %.0f%%", *body.Volume*100)
+	}
+	writeJSON(w, http.StatusOK, map[string]float64{"volume": hs.handler.OutputVolume()})
+}
+
+// handleConfig returns the configuration, or updates the device settings
+func (hs *HTTPServer) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	if r.Method == http.MethodPut {
+		var update DeviceUpdate
+		if err := decodeBody(r, &update); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := hs.handler.UpdateDevice(update); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, hs.handler.ConfigReport())
+}
+
+// handleStatus returns the application status
+func (hs *HTTPServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, hs.handler.StatusReport())
+}
+
+// handleEvents streams events as Server-Sent Events. The optional types
+// query parameter is a comma-separated list of type filters.
+func (hs *HTTPServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok || hs.events == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "event stream not available"})
+		return
+	}
+
+	var types []events.Type
+	for _, t := range strings.Split(r.URL.Query().Get("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, events.Type(t))
+		}
+	}
+	sub := hs.events.Subscribe(0, types...)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case event := <-sub.C:
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Failed to encode event %s: %v", event.Type, err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// allowMethods rejects requests with other methods
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	return false
+}
+
+// decodeBody decodes a JSON request body
+func decodeBody(r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 64*1024))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// writeError reports a refused request, 409 if the application state
+// prevents it
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, ErrUnavailable) {
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeJSON writes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write HTTP response: %v", err)
+	}
+}
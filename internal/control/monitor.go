@@ -5,30 +5,60 @@ import (
 	"context"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"websocket_client_chat/internal/config"
+	"websocket_client_chat/internal/events"
 )
 
 // Command is the control command type
 type Command string
 
 const (
-	CmdStartRecording Command = "1" // Start recording
-	CmdStopRecording  Command = "2" // Stop recording
-	CmdTestRecording  Command = "3" // Test recording
-	CmdQuit           Command = "q" // Quit program
+	CmdStartRecording  Command = "1" // Start recording
+	CmdStopRecording   Command = "2" // Stop recording
+	CmdTestRecording   Command = "3" // Test recording
+	CmdCancelOutput    Command = "4" // Cancel the assistant's current answer
+	CmdClearContext    Command = "5" // Clear the conversation context on the server
+	CmdNewConversation Command = "6" // Cancel, clear context and start a new conversation
+	CmdStatus          Command = "7" // Log the application status
+	CmdQuit            Command = "q" // Quit program
 )
 
+// commandAliases maps accepted input spellings to commands
+var commandAliases = map[string]Command{
+	"1": CmdStartRecording, "start": CmdStartRecording,
+	"2": CmdStopRecording, "stop": CmdStopRecording,
+	"3": CmdTestRecording, "test": CmdTestRecording,
+	"4": CmdCancelOutput, "cancel": CmdCancelOutput,
+	"5": CmdClearContext, "clear": CmdClearContext,
+	"6": CmdNewConversation, "new": CmdNewConversation,
+	"7": CmdStatus, "status": CmdStatus,
+	"q": CmdQuit, "quit": CmdQuit, "exit": CmdQuit,
+}
+
+// ParseCommand converts user input (a command code or name) into a command
+func ParseCommand(input string) (Command, bool) {
+	cmd, ok := commandAliases[strings.ToLower(strings.TrimSpace(input))]
+	return cmd, ok
+}
+
 // Handler is the control command handler interface
 type Handler interface {
 	HandleCommand(cmd Command)
 }
 
+// publishCommand publishes a received command to the event bus
+func publishCommand(bus *events.Bus, source string, cmd Command) {
+	bus.Publish(events.TypeCommand, source, events.Command{Command: string(cmd)})
+}
+
 // FileMonitor is the file monitor
 type FileMonitor struct {
 	config  *config.ControlConfig
 	handler Handler
+	events  *events.Bus // Receives the commands read (optional)
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -46,6 +76,11 @@ func NewFileMonitor(parentCtx context.Context, cfg *config.ControlConfig, handle
 	}
 }
 
+// SetEventBus publishes the commands read to bus. Must be called before Start.
+func (fm *FileMonitor) SetEventBus(bus *events.Bus) {
+	fm.events = bus
+}
+
 // Start starts file monitoring
 func (fm *FileMonitor) Start() error {
 	// Initialize control file
@@ -103,12 +138,18 @@ func (fm *FileMonitor) checkFile(lastCmd *string) error {
 	log.Printf("Command detected: %s", currentValue)
 
 	// Process command
-	cmd := Command(currentValue)
-	fm.handler.HandleCommand(cmd)
+	if cmd, ok := ParseCommand(currentValue); ok {
+		publishCommand(fm.events, events.SourceFile, cmd)
+		fm.handler.HandleCommand(cmd)
+	} else {
+		log.Printf("Unknown command: %s", currentValue)
+	}
 
-	// Clear control file
+	// Clear control file. Once cleared, the same command may be written again.
 	if err := os.WriteFile(fm.config.FilePath, []byte{}, 0644); err != nil {
 		log.Printf("Failed to clear control file: %v", err)
+	} else {
+		*lastCmd = ""
 	}
 
 	return nil
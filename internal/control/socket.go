@@ -0,0 +1,371 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"websocket_client_chat/internal/config"
+	"websocket_client_chat/internal/events"
+)
+
+// JSON-RPC 2.0 error codes, and the application's own
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcUnavailable    = 1 // Not possible in the current mode or state (ErrUnavailable)
+	rpcFailed         = 2 // Accepted but failed
+)
+
+const (
+	maxRequestSize = 64 * 1024       // Longest request line
+	writeTimeout   = 5 * time.Second // A client not reading for this long is dropped
+)
+
+// rpcRequest is a JSON-RPC request; requests without an ID get no response
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is a JSON-RPC response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is the error of a failed request
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcNotification carries a streamed event to a subscribed client
+type rpcNotification struct {
+	JSONRPC string       `json:"jsonrpc"`
+	Method  string       `json:"method"`
+	Params  events.Event `json:"params"`
+}
+
+// SocketMonitor accepts JSON-RPC 2.0 requests, one per line, from any
+// number of clients on a Unix socket. Every request with an ID is answered
+// with a result or an error, and a client can subscribe to the event bus.
+type SocketMonitor struct {
+	config  *config.ControlConfig
+	handler APIHandler
+	events  *events.Bus // Streamed to subscribed clients and receives the commands (optional)
+
+	listener net.Listener
+	conns    map[*socketConn]struct{}
+	mutex    sync.Mutex // Protects conns
+	wg       sync.WaitGroup
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewSocketMonitor creates a new control socket monitor
+func NewSocketMonitor(parentCtx context.Context, cfg *config.ControlConfig, handler APIHandler) *SocketMonitor {
+	ctx, cancel := context.WithCancel(parentCtx)
+
+	return &SocketMonitor{
+		config:  cfg,
+		handler: handler,
+		conns:   make(map[*socketConn]struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// SetEventBus lets clients subscribe to bus and publishes the commands
+// received to it. Must be called before Start.
+func (sm *SocketMonitor) SetEventBus(bus *events.Bus) {
+	sm.events = bus
+}
+
+// Start listens on the control socket and accepts clients in the background
+func (sm *SocketMonitor) Start() error {
+	listener, err := listenUnix(sm.config.SocketPath)
+	if err != nil {
+		return err
+	}
+	sm.listener = listener
+
+	sm.wg.Add(1)
+	go sm.acceptLoop()
+	log.Printf("Control socket listening on %s", sm.config.SocketPath)
+	return nil
+}
+
+// Stop closes the socket and disconnects all clients
+func (sm *SocketMonitor) Stop() error {
+	sm.cancel()
+	if sm.listener == nil {
+		return nil
+	}
+	err := sm.listener.Close()
+
+	sm.mutex.Lock()
+	for conn := range sm.conns {
+		conn.conn.Close()
+	}
+	sm.mutex.Unlock()
+
+	sm.wg.Wait()
+	os.Remove(sm.config.SocketPath)
+	return err
+}
+
+// acceptLoop accepts clients until the socket is closed
+func (sm *SocketMonitor) acceptLoop() {
+	defer sm.wg.Done()
+
+	for {
+		conn, err := sm.listener.Accept()
+		if err != nil {
+			if sm.ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Control socket accept failed: %v", err)
+			}
+			return
+		}
+
+		client := &socketConn{monitor: sm, conn: conn}
+		sm.mutex.Lock()
+		sm.conns[client] = struct{}{}
+		sm.mutex.Unlock()
+
+		sm.wg.Add(1)
+		go func() {
+			defer sm.wg.Done()
+			client.serve()
+
+			sm.mutex.Lock()
+			delete(sm.conns, client)
+			sm.mutex.Unlock()
+		}()
+	}
+}
+
+// socketConn is one connected client
+type socketConn struct {
+	monitor *SocketMonitor
+	conn    net.Conn
+
+	writeMutex sync.Mutex // Serializes responses and notifications
+
+	sub      *events.Subscription // Active event subscription, nil if none
+	subMutex sync.Mutex
+	subDone  chan struct{} // Closed when the subscription's forwarder exits
+}
+
+// serve answers the client's requests in order until it disconnects
+func (c *socketConn) serve() {
+	defer c.conn.Close()
+	defer c.unsubscribe()
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxRequestSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			c.write(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcParseError, err.Error()}})
+			continue
+		}
+		if req.Method == "" {
+			c.write(rpcResponse{JSONRPC: "2.0", ID: responseID(req.ID), Error: &rpcError{rpcInvalidRequest, "method is required"}})
+			continue
+		}
+
+		result, rpcErr := c.call(req.Method, req.Params)
+		if req.ID == nil {
+			continue // Notification
+		}
+		c.write(rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr})
+	}
+	if err := scanner.Err(); err != nil && c.monitor.ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+		log.Printf("Control socket client error: %v", err)
+	}
+}
+
+// call runs one method
+func (c *socketConn) call(method string, params json.RawMessage) (any, *rpcError) {
+	handler := c.monitor.handler
+
+	switch method {
+	case "command":
+		var p struct {
+			Command string `json:"command"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		cmd, ok := ParseCommand(p.Command)
+		if !ok {
+			return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("unknown command %q", p.Command)}
+		}
+		log.Printf("Control socket command: %s", p.Command)
+		publishCommand(c.monitor.events, events.SourceSocket, cmd)
+		if err := handler.ExecuteCommandWait(cmd); err != nil {
+			return nil, applicationError(err)
+		}
+		return map[string]string{"command": string(cmd)}, nil
+
+	case "wake":
+		if err := handler.Wake("Control socket"); err != nil {
+			return nil, applicationError(err)
+		}
+		return map[string]string{}, nil
+
+	case "status":
+		return handler.StatusReport(), nil
+
+	case "config":
+		return handler.ConfigReport(), nil
+
+	case "config.update":
+		var update DeviceUpdate
+		if err := decodeParams(params, &update); err != nil {
+			return nil, err
+		}
+		if err := handler.UpdateDevice(update); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+		return handler.ConfigReport(), nil
+
+	case "volume":
+		var p struct {
+			Volume *float64 `json:"volume"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Volume != nil {
+			if *p.Volume < 0 || *p.Volume > 1 {
+				return nil, &rpcError{rpcInvalidParams, "volume must be between 0 and 1"}
+			}
+			handler.SetOutputVolume(*p.Volume)
+			log.Printf("Control socket: output volume set to %.0f%%", *p.Volume*100)
+		}
+		return map[string]float64{"volume": handler.OutputVolume()}, nil
+
+	case "subscribe":
+		var p struct {
+			Types []events.Type `json:"types"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if c.monitor.events == nil {
+			return nil, &rpcError{rpcUnavailable, "event stream not available"}
+		}
+		c.subscribe(p.Types)
+		return map[string]any{"types": p.Types}, nil
+
+	case "unsubscribe":
+		c.unsubscribe()
+		return map[string]string{}, nil
+
+	default:
+		return nil, &rpcError{rpcMethodNotFound, fmt.Sprintf("unknown method %q", method)}
+	}
+}
+
+// subscribe streams the events of the given types (all if none), replacing
+// an earlier subscription
+func (c *socketConn) subscribe(types []events.Type) {
+	c.unsubscribe()
+
+	c.subMutex.Lock()
+	defer c.subMutex.Unlock()
+	sub := c.monitor.events.Subscribe(0, types...)
+	done := make(chan struct{})
+	c.sub, c.subDone = sub, done
+
+	go func() {
+		defer close(done)
+		for event := range sub.C {
+			if err := c.write(rpcNotification{JSONRPC: "2.0", Method: "event", Params: event}); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// unsubscribe ends the event stream, if any. Events already being written
+// are finished first, so no notification follows the unsubscribe response.
+func (c *socketConn) unsubscribe() {
+	c.subMutex.Lock()
+	defer c.subMutex.Unlock()
+	if c.sub == nil {
+		return
+	}
+	c.sub.Close()
+	<-c.subDone
+	if dropped := c.sub.Dropped(); dropped > 0 {
+		log.Printf("Control socket client missed %d event(s)", dropped)
+	}
+	c.sub, c.subDone = nil, nil
+}
+
+// write sends one JSON line to the client
+func (c *socketConn) write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to encode control socket message: %v", err)
+		return err
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err = c.conn.Write(append(data, '\n')); err != nil {
+		// A partial line cannot be recovered from
+		c.conn.Close()
+	}
+	return err
+}
+
+// decodeParams decodes the method parameters, which may be omitted
+func decodeParams(params json.RawMessage, v any) *rpcError {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{rpcInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+// applicationError converts an error of the handler
+func applicationError(err error) *rpcError {
+	if errors.Is(err, ErrUnavailable) {
+		return &rpcError{rpcUnavailable, err.Error()}
+	}
+	return &rpcError{rpcFailed, err.Error()}
+}
+
+// responseID returns the ID to answer an invalid request with
+func responseID(id json.RawMessage) json.RawMessage {
+	if id == nil {
+		return json.RawMessage("null")
+	}
+	return id
+}
@@ -7,11 +7,14 @@ import (
 	"log"
 	"os"
 	"strings"
+
+	"websocket_client_chat/internal/events"
 )
 
 // StdinMonitor is the stdin monitor (for debugging)
 type StdinMonitor struct {
 	handler Handler
+	events  *events.Bus // Receives the commands entered (optional)
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -28,6 +31,11 @@ func NewStdinMonitor(parentCtx context.Context, handler Handler) *StdinMonitor {
 	}
 }
 
+// SetEventBus publishes the commands entered to bus. Must be called before Start.
+func (sm *StdinMonitor) SetEventBus(bus *events.Bus) {
+	sm.events = bus
+}
+
 // Start starts stdin monitoring
 func (sm *StdinMonitor) Start() error {
 	go sm.monitorLoop()
@@ -49,6 +57,10 @@ func (sm *StdinMonitor) monitorLoop() {
 	fmt.Println("  1 or start - Start recording")
 	fmt.Println("  2 or stop  - Stop recording and send")
 	fmt.Println("  3 or test  - Test recording (record 5s and save to file)")
+	fmt.Println("  4 or cancel - Cancel the current answer")
+	fmt.Println("  5 or clear  - Clear conversation context")
+	fmt.Println("  6 or new    - Start a new conversation")
+	fmt.Println("  7 or status - Log the application status")
 	fmt.Println("  q or quit  - Exit program")
 	fmt.Println("==================")
 
@@ -78,28 +90,32 @@ func (sm *StdinMonitor) monitorLoop() {
 
 // processCommand processes a command
 func (sm *StdinMonitor) processCommand(input string) {
-	input = strings.ToLower(input)
+	cmd, ok := ParseCommand(input)
+	if !ok {
+		fmt.Printf("Unknown command: %s\n", input)
+		return
+	}
 
-	var cmd Command
-	switch input {
-	case "1", "start":
-		cmd = CmdStartRecording
+	switch cmd {
+	case CmdStartRecording:
 		log.Println("Command: Start recording")
-	case "2", "stop":
-		cmd = CmdStopRecording
+	case CmdStopRecording:
 		log.Println("Command: Stop recording")
-	case "3", "test":
-		cmd = CmdTestRecording
+	case CmdTestRecording:
 		log.Println("Command: Test recording (will record 5s and save)")
-	case "q", "quit", "exit":
+	case CmdCancelOutput:
+		log.Println("Command: Cancel output")
+	case CmdClearContext:
+		log.Println("Command: Clear context")
+	case CmdNewConversation:
+		log.Println("Command: New conversation")
+	case CmdStatus:
+		log.Println("Command: Status")
+	case CmdQuit:
 		log.Println("Command: Exit program")
-		sm.handler.HandleCommand(CmdQuit)
-		return
-	default:
-		fmt.Printf("Unknown command: %s\n", input)
-		return
 	}
 
 	// Call handler
+	publishCommand(sm.events, events.SourceStdin, cmd)
 	sm.handler.HandleCommand(cmd)
 }
@@ -0,0 +1,176 @@
+// Package conversation keeps the backend conversation ID across reconnects
+// and restarts
+package conversation
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"websocket_client_chat/internal/config"
+)
+
+// state is the persisted form of the store
+type state struct {
+	ConversationID string    `json:"conversationId"`
+	LastActive     time.Time `json:"lastActive"`
+}
+
+// Store remembers the active conversation ID. The ID expires when no
+// activity was recorded for the idle timeout, so a device picked up after
+// a long pause starts a fresh conversation.
+type Store struct {
+	path        string
+	idleTimeout time.Duration
+	enableDebug bool
+
+	id         string
+	lastActive time.Time
+	mutex      sync.Mutex
+}
+
+// NewStore creates a store and loads the persisted state, if any
+func NewStore(cfg *config.ConversationConfig, enableDebug bool) *Store {
+	s := &Store{
+		path:        cfg.StateFile,
+		idleTimeout: cfg.IdleTimeout,
+		enableDebug: enableDebug,
+	}
+	s.load()
+	return s
+}
+
+// ID returns the active conversation ID, or "" if there is none or it expired
+func (s *Store) ID() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.id != "" && s.expired(time.Now()) {
+		log.Printf("[Conversation] %s expired after %v idle, starting a new conversation",
+			s.id, time.Since(s.lastActive).Round(time.Second))
+		s.id = ""
+		s.save()
+	}
+	return s.id
+}
+
+// Set records the conversation ID reported by the server and marks it active
+func (s *Store) Set(id string) {
+	if id == "" {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if id != s.id {
+		if s.id != "" || s.enableDebug {
+			log.Printf("[Conversation] Active conversation: %s", id)
+		}
+		s.id = id
+	}
+	s.lastActive = time.Now()
+	s.save()
+}
+
+// Touch marks the conversation active without changing it. It is called on
+// conversation traffic and is persisted lazily by the next Set or Clear.
+func (s *Store) Touch() {
+	s.mutex.Lock()
+	if s.id != "" {
+		s.lastActive = time.Now()
+	}
+	s.mutex.Unlock()
+}
+
+// Clear forgets the conversation so the next updateConfig starts a new one
+func (s *Store) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.id == "" {
+		return
+	}
+	log.Printf("[Conversation] Cleared %s", s.id)
+	s.id = ""
+	s.lastActive = time.Time{}
+	s.save()
+}
+
+// Flush persists the last activity time
+func (s *Store) Flush() {
+	s.mutex.Lock()
+	s.save()
+	s.mutex.Unlock()
+}
+
+// expired reports whether the idle timeout has passed. Must be called with mutex held.
+func (s *Store) expired(now time.Time) bool {
+	return s.idleTimeout > 0 && now.Sub(s.lastActive) > s.idleTimeout
+}
+
+// load reads the state file. A missing or corrupt file starts empty.
+func (s *Store) load() {
+	if s.path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[Conversation] Failed to read state file: %v", err)
+		}
+		return
+	}
+
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		log.Printf("[Conversation] Ignoring corrupt state file %s: %v", s.path, err)
+		return
+	}
+
+	s.id = st.ConversationID
+	s.lastActive = st.LastActive
+	if s.id != "" && s.expired(time.Now()) {
+		if s.enableDebug {
+			log.Printf("[Conversation] Persisted conversation %s has expired", s.id)
+		}
+		s.id = ""
+		return
+	}
+	if s.id != "" {
+		log.Printf("[Conversation] Resuming conversation %s", s.id)
+	}
+}
+
+// save writes the state file atomically. Must be called with mutex held.
+func (s *Store) save() {
+	if s.path == "" {
+		return
+	}
+
+	if err := s.write(state{ConversationID: s.id, LastActive: s.lastActive}); err != nil {
+		log.Printf("[Conversation] Failed to save state: %v", err)
+	}
+}
+
+// write replaces the state file with st
+func (s *Store) write(st state) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("json encoding failed: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuffer is the subscription channel size used for a buffer of 0
+const DefaultBuffer = 64
+
+// Bus delivers published events to subscribers. Publishing never blocks:
+// an event that does not fit into a subscriber's channel is dropped for
+// that subscriber and counted. A nil *Bus discards events, so publishers
+// need no check.
+type Bus struct {
+	subs  map[*Subscription]struct{}
+	mutex sync.RWMutex
+	seq   atomic.Uint64
+}
+
+// NewBus creates an event bus without subscribers
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Publish sends an event to every subscriber whose filter matches its type
+func (b *Bus) Publish(t Type, source string, data any) {
+	if b == nil {
+		return
+	}
+
+	event := Event{
+		Seq:    b.seq.Add(1),
+		Type:   t,
+		Time:   time.Now(),
+		Source: source,
+		Data:   data,
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for sub := range b.subs {
+		if !sub.matches(t) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribe returns a subscription receiving the events of the given types
+// (all events if none are given). buffer is the channel size; a subscriber
+// that falls further behind loses events.
+func (b *Bus) Subscribe(buffer int, types ...Type) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	ch := make(chan Event, buffer)
+	sub := &Subscription{C: ch, ch: ch, bus: b, types: types}
+
+	b.mutex.Lock()
+	b.subs[sub] = struct{}{}
+	b.mutex.Unlock()
+	return sub
+}
+
+// Subscription is a subscriber's view of the bus
+type Subscription struct {
+	C <-chan Event // Closed by Close
+
+	ch      chan Event
+	bus     *Bus
+	types   []Type
+	dropped atomic.Uint64
+}
+
+// Close removes the subscription from the bus and closes C. It may be
+// called more than once.
+func (s *Subscription) Close() {
+	s.bus.mutex.Lock()
+	defer s.bus.mutex.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.ch)
+	}
+}
+
+// Dropped returns the number of events lost because C was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// matches reports whether the subscription's filter accepts the type
+func (s *Subscription) matches(t Type) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, filter := range s.types {
+		if Matches(filter, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether filter selects the type: the same type, or a
+// prefix of it ending at a dot
+func Matches(filter, t Type) bool {
+	return t == filter || strings.HasPrefix(string(t), string(filter)+".")
+}
@@ -0,0 +1,117 @@
+// Package events is an in-process publish/subscribe bus for application
+// events (state changes, transcripts, connection and playback changes,
+// control input), so outputs such as LEDs, displays and scripts do not
+// depend on the log. The schema is described in EVENTS.md.
+package events
+
+import "time"
+
+// Type identifies an event. Types are dotted; a subscription filter matches
+// a type exactly or any type below it ("connection" matches
+// "connection.connected").
+type Type string
+
+const (
+	TypeStateChanged       Type = "state.changed"           // Session state transition (StateChange)
+	TypeConnected          Type = "connection.connected"    // WebSocket connected (Connection)
+	TypeReconnecting       Type = "connection.reconnecting" // Connection attempt failed, retrying (Connection)
+	TypeDisconnected       Type = "connection.disconnected" // WebSocket connection lost (Connection)
+	TypeTranscriptStream   Type = "transcript.stream"       // Partial text of a chat (Transcript)
+	TypeTranscriptComplete Type = "transcript.complete"     // Final text of a chat (Transcript)
+	TypeChatComplete       Type = "chat.complete"           // Chat finished, with errors on failure (ChatComplete)
+	TypePlaybackStarted    Type = "playback.started"        // Response audio started playing (Playback)
+	TypePlaybackStopped    Type = "playback.stopped"        // Response audio ended or was interrupted (Playback)
+	TypeWake               Type = "wake"                    // A session was woken (Wake)
+	TypeBargeIn            Type = "bargein"                 // Local barge-in decision (BargeIn)
+	TypeCommand            Type = "control.command"         // Control command received (Command)
+	TypeGpio               Type = "control.gpio"            // GPIO edge detected (Gpio)
+)
+
+// Event sources
+const (
+	SourceApp       = "app"
+	SourceWebSocket = "websocket"
+	SourcePlayer    = "player"
+	SourceFile      = "control.file"
+	SourceStdin     = "control.stdin"
+	SourceGpio      = "control.gpio"
+	SourceHTTP      = "control.http"
+	SourceSocket    = "control.socket"
+)
+
+// Event is one published event. Data holds the payload struct documented
+// for the type.
+type Event struct {
+	Seq    uint64    `json:"seq"` // Increases by one per published event
+	Type   Type      `json:"type"`
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	Data   any       `json:"data,omitempty"`
+}
+
+// StateChange is the payload of state.changed
+type StateChange struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Event  string `json:"event"`
+	Reason string `json:"reason"`
+}
+
+// Connection is the payload of the connection.* events
+type Connection struct {
+	Endpoint string `json:"endpoint"`          // Redacted endpoint URL
+	Attempt  int    `json:"attempt,omitempty"` // Attempt number since the last successful connection
+	DelayMs  int64  `json:"delayMs,omitempty"` // Delay before the next attempt (reconnecting only)
+	Reason   string `json:"reason,omitempty"`  // Why the attempt failed or the connection dropped
+}
+
+// Transcript is the payload of the transcript.* events
+type Transcript struct {
+	ChatID         string `json:"chatId"`
+	ConversationID string `json:"conversationId"`
+	Role           string `json:"role"` // "assistant" or "user"
+	Text           string `json:"text"`
+}
+
+// ChatComplete is the payload of chat.complete
+type ChatComplete struct {
+	ChatID         string      `json:"chatId"`
+	ConversationID string      `json:"conversationId"`
+	Success        bool        `json:"success"`
+	Message        string      `json:"message,omitempty"`
+	Errors         []ChatError `json:"errors,omitempty"`
+}
+
+// ChatError is a server error reported with a failed chat
+type ChatError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Playback is the payload of the playback.* events
+type Playback struct {
+	Interrupted bool `json:"interrupted,omitempty"` // Stopped before the audio ended (stopped only)
+}
+
+// Wake is the payload of wake
+type Wake struct {
+	Source    string `json:"source"`              // "GPIO", "Push-to-talk" or "Wake word"
+	RequestID string `json:"requestId,omitempty"` // Request ID of the new session
+}
+
+// BargeIn is the payload of bargein
+type BargeIn struct {
+	Decision string  `json:"decision"`       // "duck", "restore" or "stop"
+	Gain     float64 `json:"gain,omitempty"` // Playback gain after the decision
+}
+
+// Command is the payload of control.command
+type Command struct {
+	Command string `json:"command"` // Command code, e.g. "1"
+}
+
+// Gpio is the payload of control.gpio
+type Gpio struct {
+	Pin  int    `json:"pin"`
+	Edge string `json:"edge"` // "wake", "press" or "release"
+}
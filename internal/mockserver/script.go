@@ -0,0 +1,203 @@
+// Package mockserver implements a local stand-in for the chat backend that
+// speaks the same WebSocket protocol as internal/websocket.Client and replies
+// with scripted output sequences.
+package mockserver
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Trigger names the client event that starts a scripted turn
+type Trigger string
+
+const (
+	TriggerWake     Trigger = "wake"     // inputWakeAudio received
+	TriggerComplete Trigger = "complete" // inputAudioComplete received
+	TriggerStream   Trigger = "stream"   // AfterChunks inputAudioStream messages received
+)
+
+// Step is a single scripted server message
+type Step struct {
+	Action  string `json:"action"`            // outputTextStream, outputTextComplete, outputAudioStream, outputAudioComplete, chatComplete or cancelOutput
+	DelayMs int    `json:"delayMs,omitempty"` // Delay before this step is sent
+
+	// Text steps
+	Role string `json:"role,omitempty"` // "assistant" or "user"
+	Text string `json:"text,omitempty"`
+
+	// Audio steps: either a WAV/PCM file or a generated tone
+	AudioFile       string  `json:"audioFile,omitempty"`       // Path to a 16-bit mono WAV or raw PCM file (relative to the script)
+	ToneHz          float64 `json:"toneHz,omitempty"`          // Generate a sine tone of this frequency
+	DurationMs      int     `json:"durationMs,omitempty"`      // Tone duration
+	ChunkMs         int     `json:"chunkMs,omitempty"`         // Audio chunk size in milliseconds (default 200)
+	ChunkIntervalMs int     `json:"chunkIntervalMs,omitempty"` // Delay between audio chunks (default 0, faster than real time)
+
+	// chatComplete / cancelOutput steps
+	Success    *bool  `json:"success,omitempty"`    // Defaults to true
+	Message    string `json:"message,omitempty"`    // chatComplete message
+	ErrorCode  int    `json:"errorCode,omitempty"`  // chatComplete error code (only with success=false)
+	CancelType string `json:"cancelType,omitempty"` // cancelOutput type, "voice" or "manual"
+
+	pcm []byte // Loaded or generated PCM for audio steps
+}
+
+// Turn is a scripted reply sequence started by a trigger
+type Turn struct {
+	Trigger     Trigger `json:"trigger"`
+	AfterChunks int     `json:"afterChunks,omitempty"` // For TriggerStream: number of inputAudioStream messages since the last turn
+	Steps       []Step  `json:"steps"`
+}
+
+// Script is the full set of turns played by the mock server. Turns are
+// consumed in order; when Loop is set the script restarts after the last turn.
+type Script struct {
+	SampleRate int    `json:"sampleRate"` // Sample rate of generated/loaded audio (default 16000)
+	Loop       bool   `json:"loop"`
+	Turns      []Turn `json:"turns"`
+}
+
+// LoadScript reads a JSON script from path and prepares all audio steps
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+
+	var script Script
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse script: %w", err)
+	}
+
+	if err := script.prepare(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	return &script, nil
+}
+
+// DefaultScript returns a built-in script that answers every wake or
+// completed utterance with a short text and a generated tone
+func DefaultScript() *Script {
+	script := &Script{
+		Loop: true,
+		Turns: []Turn{
+			{
+				Trigger: TriggerWake,
+				Steps: []Step{
+					{Action: "outputTextStream", Role: "user", Text: "hello"},
+					{Action: "outputTextStream", Role: "assistant", Text: "Hi, I'm listening.", DelayMs: 200},
+					{Action: "outputAudioStream", ToneHz: 440, DurationMs: 1500},
+					{Action: "outputTextComplete", Role: "assistant", Text: "Hi, I'm listening."},
+					{Action: "outputAudioComplete"},
+					{Action: "chatComplete"},
+				},
+			},
+			{
+				Trigger: TriggerComplete,
+				Steps: []Step{
+					{Action: "outputTextStream", Role: "assistant", Text: "This is a scripted answer.", DelayMs: 300},
+					{Action: "outputAudioStream", ToneHz: 660, DurationMs: 2000},
+					{Action: "outputTextComplete", Role: "assistant", Text: "This is a scripted answer."},
+					{Action: "outputAudioComplete"},
+					{Action: "chatComplete"},
+				},
+			},
+		},
+	}
+	_ = script.prepare("")
+	return script
+}
+
+// prepare fills defaults and loads or generates audio for every audio step
+func (s *Script) prepare(baseDir string) error {
+	if s.SampleRate <= 0 {
+		s.SampleRate = 16000
+	}
+	if len(s.Turns) == 0 {
+		return fmt.Errorf("script has no turns")
+	}
+
+	for ti := range s.Turns {
+		turn := &s.Turns[ti]
+		switch turn.Trigger {
+		case TriggerWake, TriggerComplete, TriggerStream:
+		default:
+			return fmt.Errorf("turn %d: unknown trigger %q", ti, turn.Trigger)
+		}
+
+		for si := range turn.Steps {
+			step := &turn.Steps[si]
+			if step.Action != "outputAudioStream" {
+				continue
+			}
+			if step.ChunkMs <= 0 {
+				step.ChunkMs = 200
+			}
+
+			switch {
+			case step.AudioFile != "":
+				path := step.AudioFile
+				if !filepath.IsAbs(path) && baseDir != "" {
+					path = filepath.Join(baseDir, path)
+				}
+				pcm, err := loadPCM(path)
+				if err != nil {
+					return fmt.Errorf("turn %d step %d: %w", ti, si, err)
+				}
+				step.pcm = pcm
+			case step.ToneHz > 0:
+				step.pcm = generateTone(step.ToneHz, step.DurationMs, s.SampleRate)
+			default:
+				return fmt.Errorf("turn %d step %d: audio step needs audioFile or toneHz", ti, si)
+			}
+		}
+	}
+	return nil
+}
+
+// loadPCM reads a WAV or raw PCM file and returns the raw 16-bit PCM payload
+func loadPCM(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio file: %w", err)
+	}
+
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return data, nil
+	}
+
+	// Walk the RIFF chunks looking for "data"
+	pos := 12
+	for pos+8 <= len(data) {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		pos += 8
+		if id == "data" {
+			end := pos + size
+			if end > len(data) {
+				end = len(data)
+			}
+			return data[pos:end], nil
+		}
+		pos += size + size%2
+	}
+	return nil, fmt.Errorf("no data chunk in WAV file %s", path)
+}
+
+// generateTone returns a 16-bit mono sine tone as little-endian PCM
+func generateTone(freq float64, durationMs int, sampleRate int) []byte {
+	if durationMs <= 0 {
+		durationMs = 1000
+	}
+	n := sampleRate * durationMs / 1000
+	pcm := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		v := int16(8000 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(v))
+	}
+	return pcm
+}
@@ -0,0 +1,612 @@
+package mockserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ws "websocket_client_chat/internal/websocket"
+
+	"github.com/gorilla/websocket"
+)
+
+// Config is the mock server configuration
+type Config struct {
+	Addr  string // Listen address, e.g. "127.0.0.1:10580"
+	Path  string // WebSocket endpoint path, e.g. "/api/v1/chat/ws"
+	Token string // If non-empty, the bearer token (or legacy "token" query parameter) must match
+
+	BinaryAudio bool // Accept the binary audio subprotocol when offered
+}
+
+// Server is a scripted mock of the chat backend
+type Server struct {
+	config   Config
+	script   *Script
+	upgrader websocket.Upgrader
+
+	sessions      map[*session]struct{}
+	sessionsMutex sync.Mutex
+
+	// Debug mode
+	enableDebug bool
+}
+
+// NewServer creates a new mock server
+func NewServer(cfg Config, script *Script, enableDebug bool) *Server {
+	if script == nil {
+		script = DefaultScript()
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/api/v1/chat/ws"
+	}
+
+	s := &Server{
+		config:      cfg,
+		script:      script,
+		sessions:    make(map[*session]struct{}),
+		enableDebug: enableDebug,
+	}
+	if cfg.BinaryAudio {
+		s.upgrader.Subprotocols = []string{ws.BinarySubprotocol}
+	}
+	return s
+}
+
+// Handler returns an http.Handler serving the WebSocket endpoint and the
+// /mock/cancel and /mock/reconnect control endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.config.Path, s.handleWebSocket)
+	mux.HandleFunc("/mock/cancel", s.handleCancel)
+	mux.HandleFunc("/mock/reconnect", s.handleReconnect)
+	return mux
+}
+
+// ListenAndServe serves until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	httpServer := &http.Server{
+		Addr:    s.config.Addr,
+		Handler: s.Handler(),
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("[MockServer] Listening on ws://%s%s", s.config.Addr, s.config.Path)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// CancelOutput sends a server-initiated cancelOutput to every connected
+// client and stops their scripted playback. Use cancelType "voice" to
+// reproduce a barge-in detected by the backend.
+func (s *Server) CancelOutput(cancelType string) int {
+	s.sessionsMutex.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.sessionsMutex.Unlock()
+
+	for _, sess := range sessions {
+		sess.stopTurn()
+		sess.sendCancelOutput(sess.lastRequestID(), cancelType)
+	}
+	return len(sessions)
+}
+
+// Reconnect asks every connected client to reconnect, optionally to another
+// endpoint and after retryAfter seconds. With viaClose the directive is sent
+// as the reason of a close frame instead of a "reconnect" message.
+func (s *Server) Reconnect(url string, retryAfter int, viaClose bool) int {
+	s.sessionsMutex.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.sessionsMutex.Unlock()
+
+	var req ws.ReconnectRequest
+	req.Action = "reconnect"
+	req.Data.URL = url
+	req.Data.RetryAfter = retryAfter
+	req.Data.Reason = "mock server request"
+
+	for _, sess := range sessions {
+		sess.stopTurn()
+		if viaClose {
+			reason, _ := json.Marshal(req.Data)
+			sess.writeMutex.Lock()
+			_ = sess.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, string(reason)),
+				time.Now().Add(time.Second))
+			sess.writeMutex.Unlock()
+			continue
+		}
+		if err := sess.send(req); err != nil {
+			log.Printf("[MockServer] Failed to send reconnect: %v", err)
+		}
+	}
+	return len(sessions)
+}
+
+// handleReconnect handles POST /mock/reconnect?url=ws://...&retryAfter=10&close=1
+func (s *Server) handleReconnect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	retryAfter, _ := strconv.Atoi(query.Get("retryAfter"))
+	n := s.Reconnect(query.Get("url"), retryAfter, query.Get("close") != "")
+	_, _ = fmt.Fprintf(w, "sent reconnect to %d session(s)\n", n)
+}
+
+// handleCancel handles POST /mock/cancel?type=voice
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cancelType := r.URL.Query().Get("type")
+	if cancelType == "" {
+		cancelType = "voice"
+	}
+
+	n := s.CancelOutput(cancelType)
+	_, _ = fmt.Fprintf(w, "sent cancelOutput (%s) to %d session(s)\n", cancelType, n)
+}
+
+// authorized checks the Authorization header, falling back to the legacy query parameter
+func (s *Server) authorized(r *http.Request) bool {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token == s.config.Token
+	}
+	return r.URL.Query().Get("token") == s.config.Token
+}
+
+// handleWebSocket upgrades the connection and runs a session
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if s.config.Token != "" && !s.authorized(r) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[MockServer] Upgrade failed: %v", err)
+		return
+	}
+
+	sess := &session{
+		server: s,
+		conn:   conn,
+		binary: conn.Subprotocol() == ws.BinarySubprotocol,
+	}
+
+	s.sessionsMutex.Lock()
+	s.sessions[sess] = struct{}{}
+	s.sessionsMutex.Unlock()
+
+	log.Printf("[MockServer] Client connected: %s (binary audio: %v)", r.RemoteAddr, sess.binary)
+	sess.run()
+
+	s.sessionsMutex.Lock()
+	delete(s.sessions, sess)
+	s.sessionsMutex.Unlock()
+	log.Printf("[MockServer] Client disconnected: %s", r.RemoteAddr)
+}
+
+// session is one connected client
+type session struct {
+	server *Server
+	conn   *websocket.Conn
+
+	// writeMutex serializes writes, gorilla/websocket supports one writer
+	writeMutex sync.Mutex
+
+	// Binary audio framing negotiated for this connection
+	binary      bool
+	downlinkSeq uint32 // Guarded by writeMutex
+
+	// Script progress
+	mutex          sync.Mutex
+	turnIndex      int
+	streamChunks   int
+	requestID      string
+	conversationID string
+	turnCancel     chan struct{} // Closed to abort the running turn
+	turnWg         sync.WaitGroup
+}
+
+// run reads client messages until the connection closes
+func (sess *session) run() {
+	defer func() {
+		sess.stopTurn()
+		_ = sess.conn.Close()
+	}()
+
+	var est ws.EstablishConnectionResponse
+	est.Action = "establishConnection"
+	est.Success = true
+	if err := sess.send(est); err != nil {
+		return
+	}
+
+	for {
+		msgType, message, err := sess.conn.ReadMessage()
+		if err != nil {
+			if sess.server.enableDebug {
+				log.Printf("[MockServer] Read error: %v", err)
+			}
+			return
+		}
+		if msgType == websocket.BinaryMessage {
+			message, err = sess.frameToJSON(message)
+			if err != nil {
+				log.Printf("[MockServer] Invalid binary frame: %v", err)
+				continue
+			}
+		}
+
+		if err := sess.handleMessage(message); err != nil {
+			log.Printf("[MockServer] Failed to handle message: %v", err)
+		}
+	}
+}
+
+// clientMessage is the common shape of all client requests
+type clientMessage struct {
+	ID     string `json:"id"`
+	Action string `json:"action"`
+	Data   struct {
+		Buffer         string `json:"buffer"`
+		ConversationId string `json:"conversationId"`
+	} `json:"data"`
+}
+
+// frameToJSON converts a binary uplink audio frame to the equivalent JSON
+// request so both framings share one dispatch path
+func (sess *session) frameToJSON(data []byte) ([]byte, error) {
+	frame, err := ws.DecodeAudioFrame(data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := clientMessage{ID: frame.ID, Action: frame.ActionName()}
+	msg.Data.Buffer = base64.StdEncoding.EncodeToString(frame.PCM)
+	return json.Marshal(msg)
+}
+
+// handleMessage dispatches a client request
+func (sess *session) handleMessage(message []byte) error {
+	var msg clientMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	if sess.server.enableDebug && msg.Action != "inputAudioStream" {
+		log.Printf("[MockServer] <- %s (id=%s)", msg.Action, msg.ID)
+	}
+
+	sess.mutex.Lock()
+	if msg.ID != "" {
+		sess.requestID = msg.ID
+	}
+	sess.mutex.Unlock()
+
+	switch msg.Action {
+	case "updateConfig":
+		sess.mutex.Lock()
+		if msg.Data.ConversationId != "" {
+			sess.conversationID = msg.Data.ConversationId
+		} else if sess.conversationID == "" {
+			sess.conversationID = fmt.Sprintf("conv-%d", time.Now().UnixNano())
+		}
+		convID := sess.conversationID
+		sess.mutex.Unlock()
+
+		var resp ws.UpdateConfigResponse
+		resp.ID = msg.ID
+		resp.Action = "updateConfig"
+		resp.Success = true
+		resp.Message = "ok"
+		resp.Data.ConversationId = convID
+		return sess.send(resp)
+
+	case "inputWakeAudio":
+		sess.resetStreamCount()
+		sess.trigger(TriggerWake, msg.ID)
+
+	case "inputAudioStream":
+		sess.mutex.Lock()
+		sess.streamChunks++
+		sess.mutex.Unlock()
+		sess.trigger(TriggerStream, msg.ID)
+
+	case "inputAudioComplete":
+		sess.resetStreamCount()
+		sess.trigger(TriggerComplete, msg.ID)
+
+	case "cancelOutput":
+		sess.stopTurn()
+		sess.sendCancelOutput(msg.ID, "manual")
+
+	case "clearContext":
+		sess.mutex.Lock()
+		sess.conversationID = ""
+		sess.mutex.Unlock()
+
+		var resp ws.EstablishConnectionResponse
+		resp.ID = msg.ID
+		resp.Action = "clearContext"
+		resp.Success = true
+		return sess.send(resp)
+
+	default:
+		log.Printf("[MockServer] Unhandled action: %s", msg.Action)
+	}
+
+	return nil
+}
+
+// resetStreamCount resets the inputAudioStream counter
+func (sess *session) resetStreamCount() {
+	sess.mutex.Lock()
+	sess.streamChunks = 0
+	sess.mutex.Unlock()
+}
+
+// lastRequestID returns the most recent client request ID
+func (sess *session) lastRequestID() string {
+	sess.mutex.Lock()
+	defer sess.mutex.Unlock()
+	return sess.requestID
+}
+
+// trigger starts the next scripted turn if it matches the given trigger
+func (sess *session) trigger(trigger Trigger, requestID string) {
+	script := sess.server.script
+
+	sess.mutex.Lock()
+	if sess.turnIndex >= len(script.Turns) {
+		if !script.Loop {
+			sess.mutex.Unlock()
+			return
+		}
+		sess.turnIndex = 0
+	}
+
+	turn := script.Turns[sess.turnIndex]
+	if turn.Trigger != trigger || (trigger == TriggerStream && sess.streamChunks < turn.AfterChunks) {
+		sess.mutex.Unlock()
+		return
+	}
+	sess.turnIndex++
+	sess.streamChunks = 0
+	sess.mutex.Unlock()
+
+	// A new turn supersedes whatever is still playing
+	sess.stopTurn()
+
+	cancelCh := make(chan struct{})
+	sess.mutex.Lock()
+	sess.turnCancel = cancelCh
+	sess.mutex.Unlock()
+
+	sess.turnWg.Add(1)
+	go sess.playTurn(turn, requestID, cancelCh)
+}
+
+// stopTurn aborts the running turn, if any, and waits for it to exit
+func (sess *session) stopTurn() {
+	sess.mutex.Lock()
+	if sess.turnCancel != nil {
+		close(sess.turnCancel)
+		sess.turnCancel = nil
+	}
+	sess.mutex.Unlock()
+
+	sess.turnWg.Wait()
+}
+
+// playTurn sends every step of a turn, honouring delays and cancellation
+func (sess *session) playTurn(turn Turn, requestID string, cancelCh chan struct{}) {
+	defer sess.turnWg.Done()
+
+	sess.mutex.Lock()
+	convID := sess.conversationID
+	sess.mutex.Unlock()
+	chatID := fmt.Sprintf("chat-%d", time.Now().UnixNano())
+	createdAt := time.Now().Unix()
+
+	wait := func(d time.Duration) bool {
+		if d <= 0 {
+			select {
+			case <-cancelCh:
+				return false
+			default:
+				return true
+			}
+		}
+		select {
+		case <-cancelCh:
+			return false
+		case <-time.After(d):
+			return true
+		}
+	}
+
+	for _, step := range turn.Steps {
+		if !wait(time.Duration(step.DelayMs) * time.Millisecond) {
+			return
+		}
+
+		var err error
+		switch step.Action {
+		case "outputTextStream":
+			var resp ws.OutputTextStreamResponse
+			resp.ID = requestID
+			resp.Action = step.Action
+			resp.Success = true
+			resp.Data.ChatID = chatID
+			resp.Data.ConversationID = convID
+			resp.Data.Role = step.Role
+			resp.Data.Text = step.Text
+			err = sess.send(resp)
+
+		case "outputTextComplete":
+			var resp ws.OutputTextCompleteResponse
+			resp.ID = requestID
+			resp.Action = step.Action
+			resp.Success = true
+			resp.Data.ChatID = chatID
+			resp.Data.ConversationID = convID
+			resp.Data.Role = step.Role
+			resp.Data.Text = step.Text
+			err = sess.send(resp)
+
+		case "outputAudioStream":
+			chunkBytes := sess.server.script.SampleRate * 2 * step.ChunkMs / 1000
+			for pos := 0; pos < len(step.pcm); pos += chunkBytes {
+				end := min(pos+chunkBytes, len(step.pcm))
+
+				if sess.binary {
+					err = sess.sendAudioFrame(requestID, chatID, convID, step.pcm[pos:end])
+				} else {
+					var resp ws.OutputAudioStreamResponse
+					resp.ID = requestID
+					resp.Action = step.Action
+					resp.Data.ChatID = chatID
+					resp.Data.ConversationID = convID
+					resp.Data.Buffer = base64.StdEncoding.EncodeToString(step.pcm[pos:end])
+					err = sess.send(resp)
+				}
+				if err != nil {
+					break
+				}
+				if !wait(time.Duration(step.ChunkIntervalMs) * time.Millisecond) {
+					return
+				}
+			}
+
+		case "outputAudioComplete":
+			var resp ws.OutputAudioCompleteResponse
+			resp.ID = requestID
+			resp.Action = step.Action
+			resp.Data.ChatID = chatID
+			resp.Data.ConversationID = convID
+			err = sess.send(resp)
+
+		case "chatComplete":
+			var resp ws.ChatCompleteResponse
+			resp.ID = requestID
+			resp.Action = step.Action
+			resp.Success = step.Success == nil || *step.Success
+			resp.Message = step.Message
+			resp.Data.ChatID = chatID
+			resp.Data.ConversationID = convID
+			resp.Data.CreatedAt = createdAt
+			resp.Data.CompletedAt = time.Now().Unix()
+			if !resp.Success {
+				resp.Data.Errors = append(resp.Data.Errors, struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				}{Code: step.ErrorCode, Message: step.Message})
+			}
+			err = sess.send(resp)
+
+		case "cancelOutput":
+			cancelType := step.CancelType
+			if cancelType == "" {
+				cancelType = "voice"
+			}
+			sess.sendCancelOutput(requestID, cancelType)
+
+		default:
+			log.Printf("[MockServer] Unknown script action: %s", step.Action)
+		}
+
+		if err != nil {
+			log.Printf("[MockServer] Failed to send %s: %v", step.Action, err)
+			return
+		}
+	}
+}
+
+// sendCancelOutput sends a cancelOutput message to the client
+func (sess *session) sendCancelOutput(requestID string, cancelType string) {
+	var resp ws.CancelOutputResponse
+	resp.ID = requestID
+	resp.Action = "cancelOutput"
+	resp.Success = true
+	resp.Data.CancelType = cancelType
+	if err := sess.send(resp); err != nil {
+		log.Printf("[MockServer] Failed to send cancelOutput: %v", err)
+	}
+}
+
+// send writes a JSON message to the client
+func (sess *session) send(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("json encoding failed: %w", err)
+	}
+
+	if sess.server.enableDebug {
+		var base ws.GenericServerResponse
+		_ = json.Unmarshal(data, &base)
+		if base.Action != "outputAudioStream" {
+			log.Printf("[MockServer] -> %s", base.Action)
+		}
+	}
+
+	sess.writeMutex.Lock()
+	defer sess.writeMutex.Unlock()
+
+	if err := sess.conn.SetWriteDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return err
+	}
+	return sess.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// sendAudioFrame writes downlink audio as a binary frame
+func (sess *session) sendAudioFrame(requestID, chatID, convID string, pcm []byte) error {
+	sess.writeMutex.Lock()
+	defer sess.writeMutex.Unlock()
+
+	sess.downlinkSeq++
+	data, err := ws.EncodeAudioFrame(&ws.AudioFrame{
+		Action:         ws.FrameOutputAudioStream,
+		Sequence:       sess.downlinkSeq,
+		ID:             requestID,
+		ChatID:         chatID,
+		ConversationID: convID,
+		PCM:            pcm,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := sess.conn.SetWriteDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return err
+	}
+	return sess.conn.WriteMessage(websocket.BinaryMessage, data)
+}
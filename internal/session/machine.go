@@ -0,0 +1,316 @@
+// Package session implements the GPIO session state machine. Transitions are
+// declared in a table of state × event → next state and actions; the
+// machine owns the state and the response timer, and delegates the actions
+// (buffers, playback, backend messages) to its handler.
+package session
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrIllegalTransition is returned when an event is not allowed in the current state
+var ErrIllegalTransition = errors.New("illegal session transition")
+
+// DefaultResponseTimeout is how long WAITING_RESPONSE waits for response audio
+const DefaultResponseTimeout = 30 * time.Second
+
+// State is the session state in GPIO mode
+type State int32
+
+const (
+	StateSleeping        State = 0 // Default: buffering audio to circular wake buffer
+	StateWaitingResponse State = 1 // Waiting for wake response audio to finish playing
+	StateActive          State = 2 // Actively streaming audio to backend (after wake response)
+	StateTalking         State = 3 // Push-to-talk button held, streaming audio to backend
+)
+
+// String returns a human-readable name for the state
+func (s State) String() string {
+	switch s {
+	case StateSleeping:
+		return "SLEEPING"
+	case StateWaitingResponse:
+		return "WAITING_RESPONSE"
+	case StateActive:
+		return "ACTIVE"
+	case StateTalking:
+		return "TALKING"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", int32(s))
+	}
+}
+
+// Event drives the state machine
+type Event int
+
+const (
+	EventWake             Event = iota // Wake during a session, the session is interrupted
+	EventSessionStarted                // Config update acknowledged and wake audio sent
+	EventSilence                       // User stopped speaking
+	EventResponseComplete              // Response audio completed
+	EventVoiceInterrupt                // Server detected user speech during the response
+	EventResponseTimeout               // No response audio within the response timeout
+	EventAbort                         // Session abandoned (cancel, connection or turn lost, errors)
+	EventTalkStarted                   // Push-to-talk button pressed
+	EventTalkEnded                     // Push-to-talk button released
+)
+
+// String returns a human-readable name for the event
+func (e Event) String() string {
+	switch e {
+	case EventWake:
+		return "wake"
+	case EventSessionStarted:
+		return "sessionStarted"
+	case EventSilence:
+		return "silence"
+	case EventResponseComplete:
+		return "responseComplete"
+	case EventVoiceInterrupt:
+		return "voiceInterrupt"
+	case EventResponseTimeout:
+		return "responseTimeout"
+	case EventAbort:
+		return "abort"
+	case EventTalkStarted:
+		return "talkStarted"
+	case EventTalkEnded:
+		return "talkEnded"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(e))
+	}
+}
+
+// Action is a side effect of a transition, performed by the handler
+type Action int
+
+const (
+	ActionStopPlayback       Action = iota // Stop response playback
+	ActionClearSilenceBuffer               // Reset silence detection
+	ActionClearWakeBuffer                  // Drop buffered wake audio
+	ActionSendAudioComplete                // Tell the backend the session's audio ended
+)
+
+// String returns a human-readable name for the action
+func (a Action) String() string {
+	switch a {
+	case ActionStopPlayback:
+		return "stopPlayback"
+	case ActionClearSilenceBuffer:
+		return "clearSilenceBuffer"
+	case ActionClearWakeBuffer:
+		return "clearWakeBuffer"
+	case ActionSendAudioComplete:
+		return "sendAudioComplete"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(a))
+	}
+}
+
+// timerOp controls the response timer on a transition
+type timerOp int
+
+const (
+	timerKeep  timerOp = iota
+	timerStart         // Start (or restart) the response timer
+	timerStop          // Stop the response timer
+)
+
+// rule is one entry of the transition table
+type rule struct {
+	to      State
+	actions []Action
+	timer   timerOp
+}
+
+// key indexes the transition table
+type key struct {
+	from  State
+	event Event
+}
+
+// transitions is the declared transition table. Events missing from both
+// transitions and ignored are illegal in that state.
+var transitions = map[key]rule{
+	{StateActive, EventWake}:          {to: StateSleeping, actions: []Action{ActionStopPlayback, ActionClearSilenceBuffer, ActionClearWakeBuffer}, timer: timerStop},
+	{StateWaitingResponse, EventWake}: {to: StateSleeping, actions: []Action{ActionStopPlayback, ActionClearSilenceBuffer, ActionClearWakeBuffer}, timer: timerStop},
+	{StateTalking, EventWake}:         {to: StateSleeping, actions: []Action{ActionStopPlayback, ActionClearSilenceBuffer, ActionClearWakeBuffer}, timer: timerStop},
+
+	{StateSleeping, EventSessionStarted}: {to: StateWaitingResponse},
+
+	{StateActive, EventSilence}: {to: StateWaitingResponse, actions: []Action{ActionClearSilenceBuffer}, timer: timerStart},
+
+	{StateWaitingResponse, EventResponseComplete}: {to: StateActive, actions: []Action{ActionClearSilenceBuffer}},
+	{StateWaitingResponse, EventVoiceInterrupt}:   {to: StateActive, actions: []Action{ActionClearSilenceBuffer}, timer: timerStop},
+	{StateWaitingResponse, EventResponseTimeout}:  {to: StateSleeping, actions: []Action{ActionSendAudioComplete, ActionClearWakeBuffer}, timer: timerStop},
+
+	{StateSleeping, EventAbort}:        {to: StateSleeping, actions: []Action{ActionStopPlayback, ActionClearSilenceBuffer, ActionClearWakeBuffer}, timer: timerStop},
+	{StateWaitingResponse, EventAbort}: {to: StateSleeping, actions: []Action{ActionStopPlayback, ActionClearSilenceBuffer, ActionClearWakeBuffer}, timer: timerStop},
+	{StateActive, EventAbort}:          {to: StateSleeping, actions: []Action{ActionStopPlayback, ActionClearSilenceBuffer, ActionClearWakeBuffer}, timer: timerStop},
+	{StateTalking, EventAbort}:         {to: StateSleeping, actions: []Action{ActionStopPlayback, ActionClearSilenceBuffer, ActionClearWakeBuffer}, timer: timerStop},
+
+	// Push-to-talk: the response plays while SLEEPING, a press interrupts it
+	{StateSleeping, EventTalkStarted}: {to: StateTalking, actions: []Action{ActionStopPlayback, ActionClearWakeBuffer}, timer: timerStop},
+	{StateTalking, EventTalkEnded}:    {to: StateSleeping, actions: []Action{ActionSendAudioComplete}},
+}
+
+// ignored lists events that are expected in a state but have no effect,
+// e.g. audio completion of a response that is no longer awaited
+var ignored = map[key]bool{
+	{StateSleeping, EventResponseComplete}: true,
+	{StateActive, EventResponseComplete}:   true,
+	{StateSleeping, EventVoiceInterrupt}:   true,
+	{StateActive, EventVoiceInterrupt}:     true,
+	{StateTalking, EventResponseComplete}:  true,
+	{StateTalking, EventVoiceInterrupt}:    true,
+	{StateSleeping, EventTalkEnded}:        true, // Release after the session was aborted
+}
+
+// Transition describes a completed state change
+type Transition struct {
+	From    State
+	To      State
+	Event   Event
+	Reason  string
+	Actions []Action
+	At      time.Time
+}
+
+// Clock abstracts time so the machine can be driven without real delays
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock is the real-time clock
+type SystemClock struct{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// Sleep pauses the calling goroutine
+func (SystemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// ActionHandler performs transition actions
+type ActionHandler interface {
+	OnSessionAction(action Action, t Transition)
+}
+
+// Machine is the GPIO session state machine. State can be read at any time;
+// transitions are serialized, and their actions and hooks run before the
+// next transition starts, so they must not call Fire.
+type Machine struct {
+	clock           Clock
+	handler         ActionHandler
+	responseTimeout time.Duration
+	enableDebug     bool
+
+	state           atomic.Int32
+	waitingSince    time.Time // Response timer start, zero when stopped
+	hooks           []func(Transition)
+	transitionMutex sync.Mutex
+}
+
+// NewMachine creates a state machine in SLEEPING
+func NewMachine(clock Clock, responseTimeout time.Duration, handler ActionHandler, enableDebug bool) *Machine {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	return &Machine{
+		clock:           clock,
+		handler:         handler,
+		responseTimeout: responseTimeout,
+		enableDebug:     enableDebug,
+	}
+}
+
+// OnTransition registers a hook called after every transition and its actions
+func (m *Machine) OnTransition(hook func(Transition)) {
+	m.transitionMutex.Lock()
+	m.hooks = append(m.hooks, hook)
+	m.transitionMutex.Unlock()
+}
+
+// State returns the current state
+func (m *Machine) State() State {
+	return State(m.state.Load())
+}
+
+// Clock returns the machine's clock
+func (m *Machine) Clock() Clock {
+	return m.clock
+}
+
+// Fire applies an event. Ignored events return the current state and no
+// error; illegal events are logged and rejected with ErrIllegalTransition.
+func (m *Machine) Fire(event Event, reason string) (State, error) {
+	m.transitionMutex.Lock()
+	defer m.transitionMutex.Unlock()
+
+	from := m.State()
+	k := key{from: from, event: event}
+	r, ok := transitions[k]
+	if !ok {
+		if ignored[k] {
+			if m.enableDebug {
+				log.Printf("[Session] Ignoring %s in %s", event, from)
+			}
+			return from, nil
+		}
+		log.Printf("[Session] Rejected illegal transition: %s in %s (%s)", event, from, reason)
+		return from, fmt.Errorf("%w: %s in %s", ErrIllegalTransition, event, from)
+	}
+
+	t := Transition{From: from, To: r.to, Event: event, Reason: reason, Actions: r.actions, At: m.clock.Now()}
+	switch r.timer {
+	case timerStart:
+		m.waitingSince = t.At
+	case timerStop:
+		m.waitingSince = time.Time{}
+	}
+	m.state.Store(int32(r.to))
+
+	if m.handler != nil {
+		for _, action := range r.actions {
+			m.handler.OnSessionAction(action, t)
+		}
+	}
+	for _, hook := range m.hooks {
+		hook(t)
+	}
+	return r.to, nil
+}
+
+// ResponseActivity restarts the response timer while waiting for a
+// response, so the timeout only counts time without response audio
+func (m *Machine) ResponseActivity() {
+	m.transitionMutex.Lock()
+	if m.State() == StateWaitingResponse {
+		m.waitingSince = m.clock.Now()
+	}
+	m.transitionMutex.Unlock()
+}
+
+// ResponseTimedOut reports whether the response timer is running and has
+// exceeded the response timeout
+func (m *Machine) ResponseTimedOut() bool {
+	m.transitionMutex.Lock()
+	defer m.transitionMutex.Unlock()
+
+	if m.State() != StateWaitingResponse || m.waitingSince.IsZero() || m.responseTimeout <= 0 {
+		return false
+	}
+	return m.clock.Now().Sub(m.waitingSince) > m.responseTimeout
+}
+
+// WaitingSince returns when the response timer was started, or the zero time
+func (m *Machine) WaitingSince() time.Time {
+	m.transitionMutex.Lock()
+	defer m.transitionMutex.Unlock()
+	return m.waitingSince
+}
@@ -0,0 +1,299 @@
+package session
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testTimeout = 30 * time.Second
+
+// fakeClock is a manually advanced clock
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time        { return c.now }
+func (c *fakeClock) Sleep(d time.Duration) { c.now = c.now.Add(d) }
+
+// recorder records the actions it is asked to perform
+type recorder struct {
+	actions []Action
+}
+
+func (r *recorder) OnSessionAction(action Action, _ Transition) {
+	r.actions = append(r.actions, action)
+}
+
+// paths lead from SLEEPING to every state
+var paths = map[State][]Event{
+	StateSleeping:        nil,
+	StateWaitingResponse: {EventSessionStarted},
+	StateActive:          {EventSessionStarted, EventResponseComplete},
+	StateTalking:         {EventTalkStarted},
+}
+
+var (
+	allStates = []State{StateSleeping, StateWaitingResponse, StateActive, StateTalking}
+	allEvents = []Event{
+		EventWake, EventSessionStarted, EventSilence, EventResponseComplete, EventVoiceInterrupt,
+		EventResponseTimeout, EventAbort, EventTalkStarted, EventTalkEnded,
+	}
+	interrupt = []Action{ActionStopPlayback, ActionClearSilenceBuffer, ActionClearWakeBuffer}
+)
+
+// newMachineIn returns a machine driven into state, with the recorded
+// actions of the way there cleared
+func newMachineIn(t *testing.T, state State) (*Machine, *fakeClock, *recorder) {
+	t.Helper()
+	clock := newFakeClock()
+	rec := &recorder{}
+	m := NewMachine(clock, testTimeout, rec, false)
+	for _, event := range paths[state] {
+		if _, err := m.Fire(event, "setup"); err != nil {
+			t.Fatalf("setup %s: %v", event, err)
+		}
+	}
+	if m.State() != state {
+		t.Fatalf("setup reached %s, want %s", m.State(), state)
+	}
+	rec.actions = nil
+	return m, clock, rec
+}
+
+func TestTransitions(t *testing.T) {
+	tests := []struct {
+		from    State
+		event   Event
+		to      State
+		actions []Action
+		timer   timerOp
+	}{
+		{StateActive, EventWake, StateSleeping, interrupt, timerStop},
+		{StateWaitingResponse, EventWake, StateSleeping, interrupt, timerStop},
+		{StateTalking, EventWake, StateSleeping, interrupt, timerStop},
+		{StateSleeping, EventSessionStarted, StateWaitingResponse, nil, timerKeep},
+		{StateActive, EventSilence, StateWaitingResponse, []Action{ActionClearSilenceBuffer}, timerStart},
+		{StateWaitingResponse, EventResponseComplete, StateActive, []Action{ActionClearSilenceBuffer}, timerKeep},
+		{StateWaitingResponse, EventVoiceInterrupt, StateActive, []Action{ActionClearSilenceBuffer}, timerStop},
+		{StateWaitingResponse, EventResponseTimeout, StateSleeping, []Action{ActionSendAudioComplete, ActionClearWakeBuffer}, timerStop},
+		{StateSleeping, EventAbort, StateSleeping, interrupt, timerStop},
+		{StateWaitingResponse, EventAbort, StateSleeping, interrupt, timerStop},
+		{StateActive, EventAbort, StateSleeping, interrupt, timerStop},
+		{StateTalking, EventAbort, StateSleeping, interrupt, timerStop},
+		{StateSleeping, EventTalkStarted, StateTalking, []Action{ActionStopPlayback, ActionClearWakeBuffer}, timerStop},
+		{StateTalking, EventTalkEnded, StateSleeping, []Action{ActionSendAudioComplete}, timerKeep},
+	}
+
+	if len(tests) != len(transitions) {
+		t.Errorf("%d transitions tested, table has %d", len(tests), len(transitions))
+	}
+	for _, tt := range tests {
+		t.Run(tt.from.String()+"/"+tt.event.String(), func(t *testing.T) {
+			r, ok := transitions[key{tt.from, tt.event}]
+			if !ok {
+				t.Fatalf("transition missing from the table")
+			}
+			if r.timer != tt.timer {
+				t.Errorf("timer op %d, want %d", r.timer, tt.timer)
+			}
+
+			m, clock, rec := newMachineIn(t, tt.from)
+			var hooked []Transition
+			m.OnTransition(func(tr Transition) { hooked = append(hooked, tr) })
+
+			state, err := m.Fire(tt.event, "test")
+			if err != nil {
+				t.Fatalf("Fire: %v", err)
+			}
+			if state != tt.to || m.State() != tt.to {
+				t.Errorf("state %s (returned %s), want %s", m.State(), state, tt.to)
+			}
+			if !reflect.DeepEqual(rec.actions, tt.actions) {
+				t.Errorf("actions %v, want %v", rec.actions, tt.actions)
+			}
+
+			want := Transition{From: tt.from, To: tt.to, Event: tt.event, Reason: "test", Actions: tt.actions, At: clock.Now()}
+			if len(hooked) != 1 || !reflect.DeepEqual(hooked[0], want) {
+				t.Errorf("hook got %+v, want one %+v", hooked, want)
+			}
+		})
+	}
+}
+
+func TestIgnoredEvents(t *testing.T) {
+	tests := []struct {
+		state State
+		event Event
+	}{
+		{StateSleeping, EventResponseComplete},
+		{StateActive, EventResponseComplete},
+		{StateSleeping, EventVoiceInterrupt},
+		{StateActive, EventVoiceInterrupt},
+		{StateTalking, EventResponseComplete},
+		{StateTalking, EventVoiceInterrupt},
+		{StateSleeping, EventTalkEnded},
+	}
+
+	if len(tests) != len(ignored) {
+		t.Errorf("%d ignored events tested, table has %d", len(tests), len(ignored))
+	}
+	for _, tt := range tests {
+		t.Run(tt.state.String()+"/"+tt.event.String(), func(t *testing.T) {
+			if !ignored[key{tt.state, tt.event}] {
+				t.Fatalf("event missing from the ignored table")
+			}
+
+			m, _, rec := newMachineIn(t, tt.state)
+			hooked := 0
+			m.OnTransition(func(Transition) { hooked++ })
+
+			state, err := m.Fire(tt.event, "test")
+			if err != nil {
+				t.Fatalf("Fire: %v", err)
+			}
+			if state != tt.state || m.State() != tt.state {
+				t.Errorf("state %s (returned %s), want %s", m.State(), state, tt.state)
+			}
+			if len(rec.actions) != 0 || hooked != 0 {
+				t.Errorf("ignored event ran %v and %d hooks", rec.actions, hooked)
+			}
+		})
+	}
+}
+
+func TestIllegalTransitions(t *testing.T) {
+	for _, state := range allStates {
+		for _, event := range allEvents {
+			k := key{state, event}
+			if _, ok := transitions[k]; ok || ignored[k] {
+				continue
+			}
+			t.Run(state.String()+"/"+event.String(), func(t *testing.T) {
+				m, _, rec := newMachineIn(t, state)
+				hooked := 0
+				m.OnTransition(func(Transition) { hooked++ })
+
+				got, err := m.Fire(event, "test")
+				if !errors.Is(err, ErrIllegalTransition) {
+					t.Fatalf("error %v, want ErrIllegalTransition", err)
+				}
+				if got != state || m.State() != state {
+					t.Errorf("state %s (returned %s), want %s", m.State(), got, state)
+				}
+				if len(rec.actions) != 0 || hooked != 0 {
+					t.Errorf("illegal event ran %v and %d hooks", rec.actions, hooked)
+				}
+			})
+		}
+	}
+}
+
+func TestResponseTimer(t *testing.T) {
+	t.Run("times out after silence", func(t *testing.T) {
+		m, clock, _ := newMachineIn(t, StateActive)
+		m.Fire(EventSilence, "test")
+		if m.WaitingSince() != clock.Now() {
+			t.Errorf("timer started at %v, want %v", m.WaitingSince(), clock.Now())
+		}
+
+		clock.Sleep(testTimeout)
+		if m.ResponseTimedOut() {
+			t.Error("timed out at exactly the timeout")
+		}
+		clock.Sleep(time.Millisecond)
+		if !m.ResponseTimedOut() {
+			t.Error("not timed out after the timeout")
+		}
+	})
+
+	t.Run("activity restarts the timer", func(t *testing.T) {
+		m, clock, _ := newMachineIn(t, StateActive)
+		m.Fire(EventSilence, "test")
+
+		clock.Sleep(testTimeout - time.Second)
+		m.ResponseActivity()
+		if m.WaitingSince() != clock.Now() {
+			t.Errorf("timer at %v after activity, want %v", m.WaitingSince(), clock.Now())
+		}
+		clock.Sleep(testTimeout)
+		if m.ResponseTimedOut() {
+			t.Error("timed out within the timeout of the last activity")
+		}
+		clock.Sleep(time.Millisecond)
+		if !m.ResponseTimedOut() {
+			t.Error("not timed out after the timeout since the last activity")
+		}
+	})
+
+	t.Run("activity outside waiting is ignored", func(t *testing.T) {
+		m, _, _ := newMachineIn(t, StateActive)
+		m.ResponseActivity()
+		if !m.WaitingSince().IsZero() {
+			t.Errorf("timer started in %s", m.State())
+		}
+	})
+
+	t.Run("session start does not start the timer", func(t *testing.T) {
+		m, clock, _ := newMachineIn(t, StateWaitingResponse)
+		clock.Sleep(2 * testTimeout)
+		if m.ResponseTimedOut() {
+			t.Error("timed out without a running timer")
+		}
+		if !m.WaitingSince().IsZero() {
+			t.Errorf("timer started at %v", m.WaitingSince())
+		}
+	})
+
+	t.Run("voice interrupt stops the timer", func(t *testing.T) {
+		m, clock, _ := newMachineIn(t, StateActive)
+		m.Fire(EventSilence, "test")
+		m.Fire(EventVoiceInterrupt, "test")
+		if !m.WaitingSince().IsZero() {
+			t.Errorf("timer still started at %v", m.WaitingSince())
+		}
+		clock.Sleep(2 * testTimeout)
+		if m.ResponseTimedOut() {
+			t.Errorf("timed out in %s", m.State())
+		}
+	})
+
+	t.Run("no timeout outside waiting", func(t *testing.T) {
+		m, clock, _ := newMachineIn(t, StateActive)
+		m.Fire(EventSilence, "test")
+		m.Fire(EventResponseComplete, "test")
+		clock.Sleep(2 * testTimeout)
+		if m.ResponseTimedOut() {
+			t.Errorf("timed out in %s", m.State())
+		}
+	})
+
+	t.Run("zero timeout disables the timer", func(t *testing.T) {
+		clock := newFakeClock()
+		m := NewMachine(clock, 0, nil, false)
+		for _, event := range paths[StateActive] {
+			m.Fire(event, "setup")
+		}
+		m.Fire(EventSilence, "test")
+		clock.Sleep(time.Hour)
+		if m.ResponseTimedOut() {
+			t.Error("timed out with the timeout disabled")
+		}
+	})
+
+	t.Run("wake and abort stop the timer", func(t *testing.T) {
+		for _, event := range []Event{EventWake, EventAbort, EventResponseTimeout} {
+			m, _, _ := newMachineIn(t, StateActive)
+			m.Fire(EventSilence, "test")
+			m.Fire(event, "test")
+			if !m.WaitingSince().IsZero() {
+				t.Errorf("%s: timer still started at %v", event, m.WaitingSince())
+			}
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package wakeword
+
+import (
+	"math"
+	"math/cmplx"
+
+	"websocket_client_chat/pkg/utils"
+)
+
+// Feature extraction parameters for 16 kHz speech
+const (
+	frameDuration = 0.025 // Analysis window in seconds
+	hopDuration   = 0.010 // Frame step in seconds
+	melBands      = 26
+	cepstra       = 12 // MFCC coefficients kept, c0 (loudness) excluded
+	preEmphasis   = 0.97
+)
+
+// frame holds the features of one analysis window
+type frame struct {
+	mfcc []float64 // L2-normalized so frames compare by shape, not level
+	rms  float64
+}
+
+// featureExtractor turns a sample stream into MFCC frames
+type featureExtractor struct {
+	frameSize int
+	hopSize   int
+	fftSize   int
+	window    []float64
+	filters   [][]float64 // Mel filterbank over the FFT bins
+	dct       [][]float64 // DCT-II rows for c1..cepstra
+
+	pending []float64 // Samples not yet consumed by a full frame
+	prev    float64   // Last sample, for pre-emphasis across chunks
+}
+
+// newFeatureExtractor creates an extractor for the given sample rate
+func newFeatureExtractor(sampleRate int) *featureExtractor {
+	frameSize := int(frameDuration * float64(sampleRate))
+	fftSize := 1
+	for fftSize < frameSize {
+		fftSize <<= 1
+	}
+
+	fe := &featureExtractor{
+		frameSize: frameSize,
+		hopSize:   int(hopDuration * float64(sampleRate)),
+		fftSize:   fftSize,
+		window:    make([]float64, frameSize),
+		filters:   melFilterbank(melBands, fftSize, sampleRate),
+		dct:       make([][]float64, cepstra),
+	}
+	for i := range fe.window {
+		fe.window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(frameSize-1))
+	}
+	for k := range fe.dct {
+		fe.dct[k] = make([]float64, melBands)
+		for n := range fe.dct[k] {
+			fe.dct[k][n] = math.Cos(math.Pi * float64(k+1) * (float64(n) + 0.5) / melBands)
+		}
+	}
+	return fe
+}
+
+// push appends samples and returns the frames completed by them
+func (fe *featureExtractor) push(samples []int16) []frame {
+	for _, s := range samples {
+		v := float64(s)
+		fe.pending = append(fe.pending, v-preEmphasis*fe.prev)
+		fe.prev = v
+	}
+
+	var frames []frame
+	for len(fe.pending) >= fe.frameSize {
+		frames = append(frames, fe.analyze(fe.pending[:fe.frameSize]))
+		fe.pending = fe.pending[fe.hopSize:]
+	}
+
+	// Compact so the backing array does not grow without bound
+	fe.pending = append(fe.pending[:0:0], fe.pending...)
+	return frames
+}
+
+// reset discards buffered samples
+func (fe *featureExtractor) reset() {
+	fe.pending = fe.pending[:0]
+	fe.prev = 0
+}
+
+// analyze computes the features of one window
+func (fe *featureExtractor) analyze(samples []float64) frame {
+	buf := make([]complex128, fe.fftSize)
+	var energy float64
+	for i, v := range samples {
+		energy += v * v
+		buf[i] = complex(v*fe.window[i], 0)
+	}
+	utils.FFT(buf)
+
+	power := make([]float64, fe.fftSize/2+1)
+	for i := range power {
+		m := cmplx.Abs(buf[i])
+		power[i] = m * m
+	}
+
+	logMel := make([]float64, melBands)
+	for b, filter := range fe.filters {
+		var sum float64
+		for i, w := range filter {
+			sum += w * power[i]
+		}
+		logMel[b] = math.Log(sum + 1e-6)
+	}
+
+	mfcc := make([]float64, cepstra)
+	var norm float64
+	for k, row := range fe.dct {
+		var sum float64
+		for n, c := range row {
+			sum += c * logMel[n]
+		}
+		mfcc[k] = sum
+		norm += sum * sum
+	}
+	if norm = math.Sqrt(norm); norm > 0 {
+		for k := range mfcc {
+			mfcc[k] /= norm
+		}
+	}
+
+	return frame{mfcc: mfcc, rms: math.Sqrt(energy / float64(len(samples)))}
+}
+
+// distance is the cosine distance of two frames (0 identical, 2 opposite)
+func distance(a, b frame) float64 {
+	var dot float64
+	for k := range a.mfcc {
+		dot += a.mfcc[k] * b.mfcc[k]
+	}
+	return 1 - dot
+}
+
+// melFilterbank builds triangular filters equally spaced on the mel scale
+func melFilterbank(bands, fftSize, sampleRate int) [][]float64 {
+	toMel := func(hz float64) float64 { return 2595 * math.Log10(1+hz/700) }
+	toHz := func(mel float64) float64 { return 700 * (math.Pow(10, mel/2595) - 1) }
+
+	maxMel := toMel(float64(sampleRate) / 2)
+	bins := make([]float64, bands+2)
+	for i := range bins {
+		hz := toHz(maxMel * float64(i) / float64(bands+1))
+		bins[i] = hz * float64(fftSize) / float64(sampleRate)
+	}
+
+	filters := make([][]float64, bands)
+	for b := range filters {
+		filters[b] = make([]float64, fftSize/2+1)
+		left, center, right := bins[b], bins[b+1], bins[b+2]
+		for i := range filters[b] {
+			f := float64(i)
+			switch {
+			case f > left && f <= center:
+				filters[b][i] = (f - left) / (center - left)
+			case f > center && f < right:
+				filters[b][i] = (right - f) / (right - center)
+			}
+		}
+	}
+	return filters
+}
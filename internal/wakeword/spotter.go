@@ -0,0 +1,34 @@
+// Package wakeword detects a spoken keyword in the microphone stream
+package wakeword
+
+import (
+	"fmt"
+
+	"websocket_client_chat/internal/config"
+)
+
+// Detection is a keyword found in the audio stream
+type Detection struct {
+	Keyword string  // Name of the matching template
+	Score   float64 // Match distance, lower is better
+}
+
+// Spotter is a keyword spotter fed with consecutive audio chunks
+type Spotter interface {
+	// Process consumes the next chunk of 16-bit mono samples and returns a
+	// detection if the keyword ended within the chunk, or nil
+	Process(samples []int16) *Detection
+
+	// Reset discards buffered audio, e.g. after the stream was interrupted
+	Reset()
+}
+
+// NewSpotter creates the spotter selected by the configuration
+func NewSpotter(cfg *config.WakeWordConfig, sampleRate int, enableDebug bool) (Spotter, error) {
+	switch cfg.Engine {
+	case "", "template":
+		return NewTemplateSpotter(cfg, sampleRate, enableDebug)
+	default:
+		return nil, fmt.Errorf("unknown wake word engine %q", cfg.Engine)
+	}
+}
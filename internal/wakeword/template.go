@@ -0,0 +1,207 @@
+package wakeword
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"websocket_client_chat/internal/config"
+	"websocket_client_chat/pkg/utils"
+)
+
+// minSpeechRMS is the level below which frames are treated as silence, both
+// when trimming templates and when deciding whether to run a match
+const minSpeechRMS = 100
+
+// template is an enrolled keyword sample
+type template struct {
+	name   string
+	frames []frame
+}
+
+// TemplateSpotter matches the stream against enrolled keyword samples with
+// subsequence dynamic time warping over MFCC frames. It needs no model, only
+// a few recordings of the keyword.
+type TemplateSpotter struct {
+	templates   []template
+	threshold   float64
+	refractory  int // Frames to skip after a detection
+	sampleRate  int
+	enableDebug bool
+
+	extractor *featureExtractor
+	history   []frame // Most recent frames, long enough for the longest template
+	maxFrames int
+	cooldown  int // Remaining refractory frames
+}
+
+// NewTemplateSpotter loads the configured templates
+func NewTemplateSpotter(cfg *config.WakeWordConfig, sampleRate int, enableDebug bool) (*TemplateSpotter, error) {
+	if len(cfg.Templates) == 0 {
+		return nil, fmt.Errorf("no wake word templates configured (wakeword_templates)")
+	}
+
+	ts := &TemplateSpotter{
+		threshold:   cfg.Threshold,
+		sampleRate:  sampleRate,
+		enableDebug: enableDebug,
+		extractor:   newFeatureExtractor(sampleRate),
+	}
+	ts.refractory = int(cfg.Refractory.Seconds() * float64(sampleRate) / float64(ts.extractor.hopSize))
+
+	for _, path := range cfg.Templates {
+		samples, rate, err := utils.ReadWAV(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := ts.Enroll(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), utils.ResampleAudio(samples, rate, sampleRate)); err != nil {
+			return nil, fmt.Errorf("template %s: %w", path, err)
+		}
+	}
+	return ts, nil
+}
+
+// Enroll adds a keyword sample. Leading and trailing silence is trimmed.
+func (ts *TemplateSpotter) Enroll(name string, samples []int16) error {
+	frames := newFeatureExtractor(ts.sampleRate).push(samples)
+
+	start, end := 0, len(frames)
+	for start < end && frames[start].rms < minSpeechRMS {
+		start++
+	}
+	for end > start && frames[end-1].rms < minSpeechRMS {
+		end--
+	}
+	if end-start < 10 {
+		return fmt.Errorf("sample has less than 100ms of speech")
+	}
+
+	ts.templates = append(ts.templates, template{name: name, frames: frames[start:end]})
+
+	// Keep room for a keyword spoken at half the template's speed
+	if n := 2 * (end - start); n > ts.maxFrames {
+		ts.maxFrames = n
+	}
+	if ts.enableDebug {
+		log.Printf("[WakeWord] Enrolled %q (%d frames)", name, end-start)
+	}
+	return nil
+}
+
+// Process implements Spotter
+func (ts *TemplateSpotter) Process(samples []int16) *Detection {
+	frames := ts.extractor.push(samples)
+	if len(frames) == 0 {
+		return nil
+	}
+
+	ts.history = append(ts.history, frames...)
+	if len(ts.history) > ts.maxFrames {
+		ts.history = append(ts.history[:0:0], ts.history[len(ts.history)-ts.maxFrames:]...)
+	}
+
+	if ts.cooldown > 0 {
+		ts.cooldown -= len(frames)
+		return nil
+	}
+
+	var best *Detection
+	for i := range ts.templates {
+		t := &ts.templates[i]
+		if len(ts.history) < 3*len(t.frames)/4 || !ts.hasSpeech(len(t.frames)) {
+			continue
+		}
+		score := matchTail(t.frames, ts.history, len(frames))
+		if best == nil || score < best.Score {
+			best = &Detection{Keyword: t.name, Score: score}
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	if ts.enableDebug {
+		log.Printf("[WakeWord] Best match %q: %.3f (threshold %.3f)", best.Keyword, best.Score, ts.threshold)
+	}
+	if best.Score > ts.threshold {
+		return nil
+	}
+
+	ts.history = ts.history[:0]
+	ts.cooldown = ts.refractory
+	return best
+}
+
+// Reset implements Spotter
+func (ts *TemplateSpotter) Reset() {
+	ts.extractor.reset()
+	ts.history = ts.history[:0]
+	ts.cooldown = 0
+}
+
+// hasSpeech reports whether the last n frames are loud enough to hold a keyword
+func (ts *TemplateSpotter) hasSpeech(n int) bool {
+	if n > len(ts.history) {
+		n = len(ts.history)
+	}
+	var sum float64
+	for _, f := range ts.history[len(ts.history)-n:] {
+		sum += f.rms * f.rms
+	}
+	return math.Sqrt(sum/float64(n)) >= minSpeechRMS
+}
+
+// matchTail returns the best normalized DTW distance between the template
+// and any segment of the stream that ends within the last `recent` frames.
+// The segment may start anywhere, and must be between three quarters and
+// twice the template's length, so partial keywords do not match.
+func matchTail(tmpl, stream []frame, recent int) float64 {
+	m, n := len(tmpl), len(stream)
+
+	// cost, path length and segment start per stream column, for the
+	// previous and the current template row
+	type cell struct {
+		cost  float64
+		steps int
+		start int
+	}
+	prev := make([]cell, n)
+	cur := make([]cell, n)
+
+	for j := 0; j < n; j++ {
+		prev[j] = cell{cost: distance(tmpl[0], stream[j]), steps: 1, start: j}
+	}
+	for i := 1; i < m; i++ {
+		for j := 0; j < n; j++ {
+			best := prev[j] // Template advances, stream stays
+			if j > 0 {
+				if c := prev[j-1]; c.cost/float64(c.steps) < best.cost/float64(best.steps) {
+					best = c // Both advance
+				}
+				if c := cur[j-1]; c.cost/float64(c.steps) < best.cost/float64(best.steps) {
+					best = c // Stream advances, template stays
+				}
+			}
+			cur[j] = cell{cost: best.cost + distance(tmpl[i], stream[j]), steps: best.steps + 1, start: best.start}
+		}
+		prev, cur = cur, prev
+	}
+
+	score := math.Inf(1)
+	from := n - recent
+	if from < 0 {
+		from = 0
+	}
+	for j := from; j < n; j++ {
+		length := j - prev[j].start + 1
+		if length < 3*m/4 || length > 2*m {
+			continue
+		}
+		if s := prev[j].cost / float64(prev[j].steps); s < score {
+			score = s
+		}
+	}
+	return score
+}
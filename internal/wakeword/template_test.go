@@ -0,0 +1,164 @@
+package wakeword
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"websocket_client_chat/internal/config"
+	"websocket_client_chat/pkg/utils"
+)
+
+const (
+	testRate  = 16000
+	testChunk = 3200 // 200ms, as delivered by the recorder
+)
+
+// vowel is a voiced sound given by its first two formants
+type vowel struct {
+	f1, f2 float64
+}
+
+var (
+	vowelA = vowel{700, 1200}
+	vowelI = vowel{300, 2300}
+	vowelU = vowel{300, 800}
+	vowelE = vowel{500, 1800}
+	vowelO = vowel{500, 900}
+)
+
+// keyword is the synthetic keyword: three vowels gliding into each other
+var keyword = []vowel{vowelA, vowelI, vowelU}
+
+// speak synthesizes the vowels, each lasting segment, as harmonics of the
+// pitch f0 shaped by the formants, with microphone noise
+func speak(vowels []vowel, segment time.Duration, f0, gain float64, rng *rand.Rand) []int16 {
+	var out []int16
+	var phase float64
+	n := int(segment.Seconds() * testRate)
+	for _, v := range vowels {
+		for i := 0; i < n; i++ {
+			phase += 2 * math.Pi * f0 / testRate
+			var s float64
+			for h := 1; float64(h)*f0 < 4000; h++ {
+				f := float64(h) * f0
+				amp := math.Exp(-math.Pow((f-v.f1)/120, 2)) + 0.6*math.Exp(-math.Pow((f-v.f2)/150, 2)) + 0.02
+				s += amp * math.Sin(float64(h)*phase)
+			}
+			fade := math.Min(1, math.Min(float64(i), float64(n-i))/400)
+			out = append(out, int16(gain*2000*s*fade+20*rng.NormFloat64()))
+		}
+	}
+	return out
+}
+
+// noise returns white noise at the given RMS level
+func noise(d time.Duration, rms float64, rng *rand.Rand) []int16 {
+	out := make([]int16, int(d.Seconds()*testRate))
+	for i := range out {
+		out[i] = int16(rms * rng.NormFloat64())
+	}
+	return out
+}
+
+// newTestSpotter enrolls the keyword from a WAV file, as the configuration does
+func newTestSpotter(t *testing.T, rng *rand.Rand) *TemplateSpotter {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hello.wav")
+	sample := speak(keyword, 200*time.Millisecond, 140, 1, rng)
+	if err := os.WriteFile(path, utils.ConvertSamplesToWAV(sample, testRate, 1, 2), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ts, err := NewTemplateSpotter(&config.WakeWordConfig{
+		Templates:  []string{path},
+		Threshold:  0.25,
+		Refractory: 2 * time.Second,
+	}, testRate, false)
+	if err != nil {
+		t.Fatalf("NewTemplateSpotter: %v", err)
+	}
+	return ts
+}
+
+// detect feeds the stream in recorder-sized chunks and returns the detections
+func detect(ts *TemplateSpotter, stream []int16) []Detection {
+	var detections []Detection
+	for start := 0; start < len(stream); start += testChunk {
+		end := min(start+testChunk, len(stream))
+		if d := ts.Process(stream[start:end]); d != nil {
+			detections = append(detections, *d)
+		}
+	}
+	return detections
+}
+
+// utterance surrounds the samples with silence
+func utterance(samples []int16) []int16 {
+	silence := make([]int16, testRate/2)
+	stream := append([]int16(nil), silence...)
+	stream = append(stream, samples...)
+	return append(stream, silence...)
+}
+
+func TestTemplateSpotterDetectsKeyword(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ts := newTestSpotter(t, rng)
+
+	// Spoken slower, higher and quieter than the enrolled sample
+	detections := detect(ts, utterance(speak(keyword, 220*time.Millisecond, 150, 0.7, rng)))
+	if len(detections) != 1 {
+		t.Fatalf("got %d detections, want 1", len(detections))
+	}
+	if d := detections[0]; d.Keyword != "hello" || d.Score > 0.25 {
+		t.Errorf("got %+v, want keyword hello within the threshold", d)
+	}
+}
+
+func TestTemplateSpotterRejectsOtherAudio(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	ts := newTestSpotter(t, rng)
+
+	tests := []struct {
+		name   string
+		stream []int16
+	}{
+		{"other vowels", utterance(speak([]vowel{vowelE, vowelO, vowelE}, 200*time.Millisecond, 140, 1, rng))},
+		{"sustained vowel", utterance(speak([]vowel{vowelA, vowelA, vowelA}, 200*time.Millisecond, 140, 1, rng))},
+		{"noise", noise(2*time.Second, 1500, rng)},
+		{"silence", make([]int16, 2*testRate)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts.Reset()
+			if detections := detect(ts, tt.stream); len(detections) != 0 {
+				t.Errorf("got detections %+v, want none", detections)
+			}
+		})
+	}
+}
+
+func TestTemplateSpotterDetectsRepetition(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	ts := newTestSpotter(t, rng)
+
+	// Spoken again after the refractory time, the keyword is detected again
+	word := speak(keyword, 200*time.Millisecond, 140, 1, rng)
+	stream := utterance(word)
+	stream = append(stream, make([]int16, 2*testRate)...)
+	stream = append(stream, utterance(word)...)
+	if detections := detect(ts, stream); len(detections) != 2 {
+		t.Errorf("got %d detections, want 2", len(detections))
+	}
+}
+
+func TestTemplateSpotterEnrollRejectsShortSample(t *testing.T) {
+	ts := &TemplateSpotter{sampleRate: testRate}
+	rng := rand.New(rand.NewSource(4))
+	if err := ts.Enroll("short", utterance(speak([]vowel{vowelA}, 50*time.Millisecond, 140, 1, rng))); err == nil {
+		t.Error("enrolled a sample with 50ms of speech")
+	}
+}
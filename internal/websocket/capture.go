@@ -0,0 +1,298 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"websocket_client_chat/internal/config"
+	"websocket_client_chat/internal/events"
+
+	"github.com/gorilla/websocket"
+)
+
+// Capture directions
+const (
+	CaptureInbound  = "in"
+	CaptureOutbound = "out"
+)
+
+// CaptureRecord is one line of a capture file.
+//
+// Audio is only stored when capture of audio is enabled. Otherwise the
+// audio buffer of JSON messages is removed from Payload, binary frames are
+// not stored, and AudioSize / AudioHash (SHA-256 of the decoded audio)
+// identify the audio instead.
+type CaptureRecord struct {
+	Time      time.Time       `json:"ts"`
+	Direction string          `json:"dir"`  // "in" or "out"
+	Type      string          `json:"type"` // "text" or "binary"
+	Action    string          `json:"action"`
+	RequestID string          `json:"id,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"` // Text message
+	Binary    []byte          `json:"binary,omitempty"`  // Binary frame
+
+	// Binary frame header, kept so frames can be replayed without audio
+	ChatID         string `json:"chatId,omitempty"`
+	ConversationID string `json:"conversationId,omitempty"`
+
+	AudioSize int    `json:"audioSize,omitempty"`
+	AudioHash string `json:"audioHash,omitempty"`
+}
+
+// captureWriter appends records to a JSONL file and rotates it by size,
+// keeping path.1 … path.N as older files
+type captureWriter struct {
+	path         string
+	maxBytes     int64
+	maxFiles     int
+	includeAudio bool
+
+	file  *os.File
+	size  int64
+	mutex sync.Mutex
+}
+
+// newCaptureWriter opens the capture file, or returns nil when capture is disabled
+func newCaptureWriter(cfg *config.CaptureConfig) (*captureWriter, error) {
+	if cfg.Path == "" {
+		return nil, nil
+	}
+
+	w := &captureWriter{
+		path:         cfg.Path,
+		maxBytes:     cfg.MaxBytes,
+		maxFiles:     cfg.MaxFiles,
+		includeAudio: cfg.IncludeAudio,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open opens the capture file for appending. Must be called with mutex held.
+func (w *captureWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open capture file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat capture file: %w", err)
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// rotate shifts older files up by one and starts a new file. Must be
+// called with mutex held.
+func (w *captureWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		log.Printf("Failed to close capture file: %v", err)
+	}
+
+	if w.maxFiles > 0 {
+		_ = os.Remove(fmt.Sprintf("%s.%d", w.path, w.maxFiles))
+		for i := w.maxFiles - 1; i >= 1; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+		}
+		if err := os.Rename(w.path, w.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate capture file: %w", err)
+		}
+	} else if err := os.Remove(w.path); err != nil {
+		return fmt.Errorf("failed to rotate capture file: %w", err)
+	}
+
+	return w.open()
+}
+
+// record writes one message. Errors are logged, capture never fails the connection.
+func (w *captureWriter) record(direction string, msgType int, data []byte) {
+	rec := w.buildRecord(direction, msgType, data)
+	line, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("Failed to encode capture record: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return
+	}
+	if w.maxBytes > 0 && w.size > 0 && w.size+int64(len(line)) > w.maxBytes {
+		if err := w.rotate(); err != nil {
+			log.Printf("Capture disabled: %v", err)
+			w.file = nil
+			return
+		}
+	}
+
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		log.Printf("Failed to write capture record: %v", err)
+	}
+}
+
+// buildRecord converts a raw message into a capture record
+func (w *captureWriter) buildRecord(direction string, msgType int, data []byte) *CaptureRecord {
+	rec := &CaptureRecord{Time: time.Now(), Direction: direction}
+
+	if msgType == websocket.BinaryMessage {
+		rec.Type = "binary"
+		frame, err := DecodeAudioFrame(data)
+		if err != nil {
+			rec.Action = "invalid"
+			rec.Binary = data
+			return rec
+		}
+		rec.Action = frame.ActionName()
+		rec.RequestID = frame.ID
+		rec.ChatID = frame.ChatID
+		rec.ConversationID = frame.ConversationID
+		rec.AudioSize, rec.AudioHash = audioDigest(frame.PCM)
+		if w.includeAudio {
+			rec.Binary = data
+		}
+		return rec
+	}
+
+	rec.Type = "text"
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		rec.Action = "invalid"
+		rec.Payload, _ = json.Marshal(string(data))
+		return rec
+	}
+	_ = json.Unmarshal(msg["action"], &rec.Action)
+	_ = json.Unmarshal(msg["id"], &rec.RequestID)
+	rec.Payload = data
+
+	// Replace the audio buffer with its digest unless audio is captured
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(msg["data"], &payload); err != nil {
+		return rec
+	}
+	var buffer string
+	if err := json.Unmarshal(payload["buffer"], &buffer); err != nil || buffer == "" {
+		return rec
+	}
+	if audio, err := base64.StdEncoding.DecodeString(buffer); err == nil {
+		rec.AudioSize, rec.AudioHash = audioDigest(audio)
+	}
+	if !w.includeAudio {
+		delete(payload, "buffer")
+		msg["data"], _ = json.Marshal(payload)
+		rec.Payload, _ = json.Marshal(msg)
+	}
+	return rec
+}
+
+// close closes the capture file
+func (w *captureWriter) close() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			log.Printf("Failed to close capture file: %v", err)
+		}
+		w.file = nil
+	}
+}
+
+// audioDigest returns the size and SHA-256 of an audio payload
+func audioDigest(audio []byte) (int, string) {
+	sum := sha256.Sum256(audio)
+	return len(audio), hex.EncodeToString(sum[:])
+}
+
+// Replay feeds the inbound messages of a capture file through the message
+// handling path, preserving the recorded gaps between messages. speed
+// scales the timing (2 replays twice as fast, 0 without delays). The client
+// must not be started; outbound sends fail while replaying.
+func (c *Client) Replay(ctx context.Context, path string, speed float64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open capture file: %w", err)
+	}
+	defer file.Close()
+
+	event := ConnectionEvent{Attempt: 1, Endpoint: "replay:" + path}
+	c.publishConnection(events.TypeConnected, event)
+	c.handler.HandleConnected(event)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), int(c.config.MaxMessageSize)*2+64*1024)
+
+	var last time.Time
+	count := 0
+	for line := 1; scanner.Scan(); line++ {
+		var rec CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			log.Printf("Replay: skipping line %d: %v", line, err)
+			continue
+		}
+		if rec.Direction != CaptureInbound {
+			continue
+		}
+
+		if !last.IsZero() && speed > 0 {
+			delay := time.Duration(float64(rec.Time.Sub(last)) / speed)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		last = rec.Time
+
+		if err := c.replayRecord(&rec); err != nil {
+			log.Printf("Replay: line %d (%s): %v", line, rec.Action, err)
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read capture file: %w", err)
+	}
+
+	log.Printf("Replay finished: %d inbound message(s) from %s", count, path)
+	return nil
+}
+
+// replayRecord delivers one captured inbound message
+func (c *Client) replayRecord(rec *CaptureRecord) error {
+	if rec.Type != "binary" {
+		return c.handleMessage(rec.Payload)
+	}
+
+	data := rec.Binary
+	if data == nil {
+		// Audio was not captured; rebuild the frame without audio
+		var err error
+		data, err = EncodeAudioFrame(&AudioFrame{
+			Action:         FrameOutputAudioStream,
+			ID:             rec.RequestID,
+			ChatID:         rec.ChatID,
+			ConversationID: rec.ConversationID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return c.handleBinaryMessage(data)
+}
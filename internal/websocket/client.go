@@ -2,27 +2,56 @@ package websocket
 
 import (
 	"context"
-	"encoding/base64"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"websocket_client_chat/internal/auth"
 	"websocket_client_chat/internal/config"
+	"websocket_client_chat/internal/events"
 
 	"github.com/gorilla/websocket"
 )
 
+// ConnectionEvent describes a connection state change
+type ConnectionEvent struct {
+	Attempt int           // Connection attempt number since the last successful connection (1-based)
+	Delay   time.Duration // Delay before the next attempt (reconnecting only)
+	Reason  string        // Why the connection failed or dropped
+
+	Endpoint string // Endpoint URL of the attempt or connection
+}
+
+// ConnectionHandler receives connection lifecycle events. Callbacks run on
+// the client's connection goroutine and must not block.
+type ConnectionHandler interface {
+	HandleConnected(event ConnectionEvent)
+	HandleDisconnected(event ConnectionEvent)
+	HandleReconnecting(event ConnectionEvent)
+}
+
 // MessageHandler defines the message handler interface
 type MessageHandler interface {
+	ConnectionHandler
+
 	HandleOutputAudioStream(resp *OutputAudioStreamResponse)
 	HandleOutputAudioComplete(resp *OutputAudioCompleteResponse)
 	HandleOutputTextStream(resp *OutputTextStreamResponse)
 	HandleOutputTextComplete(resp *OutputTextCompleteResponse)
 	HandleChatComplete(resp *ChatCompleteResponse)
 	HandleUpdateConfig(resp *UpdateConfigResponse)
+	// HandleCancelOutput is called for cancels the server initiated, not for
+	// the acknowledgment of CancelOutput or SendCancelOutput
 	HandleCancelOutput(resp *CancelOutputResponse)
+	HandleTurnLost(requestID string, reason string)
 }
 
 // Client is the WebSocket client
@@ -41,8 +70,40 @@ type Client struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
+	// Uplink send queue, drained by one writer per connection
+	queue *sendQueue
+
+	// Outstanding acknowledged requests, keyed by action and request ID, and
+	// the IDs of unacknowledged SendCancelOutput calls
+	pending      map[pendingKey]*Future
+	sentCancels  map[string]struct{}
+	pendingMutex sync.Mutex
+
+	// Access token source for the handshake (optional)
+	tokenProvider auth.TokenProvider
+
+	// Server endpoints with health tracking, and a pending server directive
+	endpoints *endpointPool
+	directive *serverDirective // Guarded by mutex
+
+	// Protocol capture (optional), opened in Start
+	capture *captureWriter
+
+	// TLS and proxy settings, built and validated in Start
+	tlsConfig *tls.Config
+	proxyFunc func(*http.Request) (*url.URL, error)
+
+	// Binary audio framing, negotiated per connection
+	binaryMode  atomic.Bool
+	uplinkSeq   uint32 // Only touched by the connection's writer
+	downlinkSeq uint32 // Only touched by the message loop
+
 	// Reconnection control
 	reconnectChan chan struct{}
+	lastTraffic   atomic.Int64 // Unix nanoseconds of the last message sent or received, for fail-back
+
+	// Event bus receiving connection changes (optional)
+	events *events.Bus
 
 	// Debug mode
 	enableDebug bool
@@ -52,22 +113,106 @@ type Client struct {
 func NewClient(parentCtx context.Context, cfg *config.WebSocketConfig, handler MessageHandler, enableDebug bool) *Client {
 	ctx, cancel := context.WithCancel(parentCtx)
 
-	return &Client{
+	c := &Client{
 		config:        cfg,
 		handler:       handler,
 		ctx:           ctx,
 		cancel:        cancel,
+		queue:         newSendQueue(cfg),
+		pending:       make(map[pendingKey]*Future),
+		sentCancels:   make(map[string]struct{}),
+		endpoints:     newEndpointPool(cfg.EndpointURLs(), cfg.BreakerThreshold, cfg.BreakerCooldown),
 		reconnectChan: make(chan struct{}, 1),
 		enableDebug:   enableDebug,
 	}
+	c.queue.onLost = handler.HandleTurnLost
+
+	return c
+}
+
+// SetTokenProvider sets the access token source. Must be called before Start.
+func (c *Client) SetTokenProvider(provider auth.TokenProvider) {
+	c.tokenProvider = provider
+}
+
+// SetEventBus publishes connection changes to bus. Must be called before Start.
+func (c *Client) SetEventBus(bus *events.Bus) {
+	c.events = bus
+}
+
+// publishConnection publishes a connection change to the event bus
+func (c *Client) publishConnection(t events.Type, event ConnectionEvent) {
+	c.events.Publish(t, events.SourceWebSocket, events.Connection{
+		Endpoint: event.Endpoint,
+		Attempt:  event.Attempt,
+		DelayMs:  event.Delay.Milliseconds(),
+		Reason:   event.Reason,
+	})
 }
 
 // Start starts the WebSocket client
 func (c *Client) Start() error {
+	urls := c.config.EndpointURLs()
+	if len(urls) == 0 {
+		return fmt.Errorf("no websocket endpoint configured")
+	}
+
+	// The TLS options apply to every endpoint, so each one is validated
+	for _, u := range urls {
+		tlsConfig, err := BuildTLSConfig(u, &c.config.TLS)
+		if err != nil {
+			return fmt.Errorf("invalid TLS configuration for %s: %w", auth.RedactURL(u), err)
+		}
+		if tlsConfig != nil {
+			c.tlsConfig = tlsConfig
+		}
+	}
+
+	proxyFunc, err := BuildProxyFunc(&c.config.Proxy)
+	if err != nil {
+		return fmt.Errorf("invalid proxy configuration: %w", err)
+	}
+	c.proxyFunc = proxyFunc
+	if proxyFunc != nil && c.enableDebug {
+		if req, err := http.NewRequest(http.MethodGet, strings.Replace(urls[0], "ws", "http", 1), nil); err == nil {
+			if proxyURL, _ := proxyFunc(req); proxyURL != nil {
+				log.Printf("Using proxy %s", auth.RedactURL(proxyURL.String()))
+			}
+		}
+	}
+
+	// Token requests take the same route as the connection
+	if p, ok := c.tokenProvider.(*auth.HTTPTokenProvider); ok {
+		p.SetTransport(c.httpTransport())
+	}
+
+	capture, err := newCaptureWriter(&c.config.Capture)
+	if err != nil {
+		return err
+	}
+	c.capture = capture
+	if capture != nil {
+		log.Printf("Capturing protocol traffic to %s", c.config.Capture.Path)
+	}
+
 	go c.connectLoop()
+	go c.offlineExpiryLoop()
 	return nil
 }
 
+// httpTransport returns an HTTP transport with the proxy and TLS settings of
+// the connection
+func (c *Client) httpTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.proxyFunc != nil {
+		transport.Proxy = c.proxyFunc
+	}
+	if c.tlsConfig != nil {
+		transport.TLSClientConfig = c.tlsConfig.Clone()
+	}
+	return transport
+}
+
 // Stop stops the WebSocket client
 func (c *Client) Stop() error {
 	c.cancel()
@@ -80,20 +225,40 @@ func (c *Client) Stop() error {
 	}
 	c.mutex.Unlock()
 
+	if c.capture != nil {
+		c.capture.close()
+	}
+
 	return nil
 }
 
-// SendMessage sends a message. All writes are serialized through writeMutex
-// because gorilla/websocket only supports one concurrent writer.
+// SendMessage queues a message on the control lane and waits until it has
+// been written. The message must carry an "action" field.
 func (c *Client) SendMessage(message interface{}) error {
-	c.mutex.RLock()
-	conn := c.conn
-	c.mutex.RUnlock()
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("json encoding failed: %w", err)
+	}
 
-	if conn == nil {
-		return fmt.Errorf("websocket not connected")
+	var base struct {
+		ID     string `json:"id"`
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(data, &base); err != nil {
+		return fmt.Errorf("failed to read message action: %w", err)
 	}
 
+	return c.enqueueAndWait(&outbound{
+		lane:      laneControl,
+		action:    base.Action,
+		requestID: base.ID,
+		message:   json.RawMessage(data),
+	})
+}
+
+// writeJSON encodes and writes a message. All writes are serialized through
+// writeMutex because gorilla/websocket only supports one concurrent writer.
+func (c *Client) writeJSON(conn *websocket.Conn, message interface{}) error {
 	data, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("json encoding failed: %w", err)
@@ -110,78 +275,100 @@ func (c *Client) SendMessage(message interface{}) error {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
 
+	if c.capture != nil {
+		c.capture.record(CaptureOutbound, websocket.TextMessage, data)
+	}
+
 	return nil
 }
 
-// SendUpdateConfig sends an update config request
-func (c *Client) SendUpdateConfig(requestID string, deviceConfig *config.DeviceConfig) error {
+// SendUpdateConfig sends an update config request. Control messages jump
+// ahead of any queued audio. An empty conversationID starts a new conversation.
+func (c *Client) SendUpdateConfig(requestID string, deviceConfig *config.DeviceConfig, conversationID string) error {
+	updateMsg := buildUpdateConfigRequest(requestID, deviceConfig, conversationID)
+	return c.enqueueAndWait(&outbound{lane: laneControl, action: updateMsg.Action, requestID: requestID, message: updateMsg})
+}
+
+// buildUpdateConfigRequest builds an update config request from the device configuration
+func buildUpdateConfigRequest(requestID string, deviceConfig *config.DeviceConfig, conversationID string) UpdateConfigRequest {
 	updateMsg := UpdateConfigRequest{
 		ID:     requestID,
 		Action: "updateConfig",
 	}
 
+	updateMsg.Data.ConversationId = conversationID
 	updateMsg.Data.SpeechRate = deviceConfig.SpeechRate
 	updateMsg.Data.VoiceID = deviceConfig.VoiceID
 	updateMsg.Data.OutputText = deviceConfig.OutputText
 	updateMsg.Data.Location.Latitude = deviceConfig.Location.Latitude
 	updateMsg.Data.Location.Longitude = deviceConfig.Location.Longitude
 	updateMsg.Data.Timezone = deviceConfig.Timezone
+	if deviceConfig.AudioCodec != "" && deviceConfig.AudioCodec != "pcm" {
+		updateMsg.Data.AudioCodec = deviceConfig.AudioCodec
+	}
 
-	return c.SendMessage(updateMsg)
+	return updateMsg
 }
 
-// SendAudioStream sends audio stream data
+// SendAudioStream queues audio stream data. It does not wait for the write;
+// chunks are sent in order by the connection's writer.
 func (c *Client) SendAudioStream(requestID string, wavData []byte) error {
-	msg := InputAudioStreamRequest{
-		ID:     requestID,
-		Action: "inputAudioStream",
-	}
-	msg.Data.Buffer = base64.StdEncoding.EncodeToString(wavData)
-
-	return c.SendMessage(msg)
+	return c.enqueue(&outbound{lane: laneAudio, action: "inputAudioStream", requestID: requestID, audio: wavData})
 }
 
-// SendAudioComplete sends audio complete request
+// SendAudioComplete queues the audio complete request behind any audio
+// already queued for the request
 func (c *Client) SendAudioComplete(requestID string, wavData []byte) error {
-	msg := InputAudioCompleteRequest{
-		ID:     requestID,
-		Action: "inputAudioComplete",
-	}
-
-	if len(wavData) > 0 {
-		msg.Data.Buffer = base64.StdEncoding.EncodeToString(wavData)
-	}
-
-	return c.SendMessage(msg)
+	return c.enqueue(&outbound{lane: laneAudio, action: "inputAudioComplete", requestID: requestID, audio: wavData})
 }
 
-// SendWakeAudio sends the circular wake buffer audio to the backend
+// SendWakeAudio queues the circular wake buffer audio for the backend
 func (c *Client) SendWakeAudio(requestID string, wavData []byte) error {
-	msg := InputWakeAudioRequest{
-		ID:     requestID,
-		Action: "inputWakeAudio",
-	}
-	msg.Data.Buffer = base64.StdEncoding.EncodeToString(wavData)
-
-	return c.SendMessage(msg)
+	return c.enqueue(&outbound{lane: laneAudio, action: "inputWakeAudio", requestID: requestID, audio: wavData})
 }
 
-// SendCancelOutput sends cancel output request
+// SendCancelOutput sends cancel output request ahead of any queued audio.
+// Its acknowledgment is not passed to HandleCancelOutput.
 func (c *Client) SendCancelOutput(requestID string) error {
 	msg := CancelOutputRequest{
 		ID:     requestID,
 		Action: "cancelOutput",
 	}
-	return c.SendMessage(msg)
+	c.pendingMutex.Lock()
+	c.sentCancels[requestID] = struct{}{}
+	c.pendingMutex.Unlock()
+
+	err := c.enqueueAndWait(&outbound{lane: laneControl, action: msg.Action, requestID: requestID, message: msg})
+	if err != nil {
+		c.pendingMutex.Lock()
+		delete(c.sentCancels, requestID)
+		c.pendingMutex.Unlock()
+	}
+	return err
 }
 
-// SendClearContext sends clear context request
+// ownCancelAck reports whether resp acknowledges a cancel sent with
+// SendCancelOutput, and forgets that cancel
+func (c *Client) ownCancelAck(resp *CancelOutputResponse) bool {
+	if resp.Data.CancelType == "voice" {
+		return false
+	}
+	c.pendingMutex.Lock()
+	defer c.pendingMutex.Unlock()
+	if _, ok := c.sentCancels[resp.ID]; !ok {
+		return false
+	}
+	delete(c.sentCancels, resp.ID)
+	return true
+}
+
+// SendClearContext sends clear context request ahead of any queued audio
 func (c *Client) SendClearContext(requestID string) error {
 	msg := ClearContextRequest{
 		ID:     requestID,
 		Action: "clearContext",
 	}
-	return c.SendMessage(msg)
+	return c.enqueueAndWait(&outbound{lane: laneControl, action: msg.Action, requestID: requestID, message: msg})
 }
 
 // IsConnected checks connection status
@@ -191,49 +378,144 @@ func (c *Client) IsConnected() bool {
 	return c.conn != nil
 }
 
-// connectLoop is the connection loop with exponential backoff
+// connectLoop is the connection loop. Each attempt goes to the endpoint
+// chosen by the pool; failed attempts back off exponentially with jitter.
 func (c *Client) connectLoop() {
 	currentDelay := c.config.ReconnectDelay
+	attempt := 0
 
 	for {
-		select {
-		case <-c.ctx.Done():
+		ep, wait := c.endpoints.next()
+		if wait > 0 {
+			log.Printf("All endpoints parked, next attempt in %.1f seconds", wait.Seconds())
+			if !c.sleep(wait) {
+				return
+			}
+		}
+		if c.ctx.Err() != nil {
 			return
-		default:
-			if err := c.connect(); err != nil {
-				log.Printf("WebSocket connection failed: %v (retrying in %.1f seconds)", err, currentDelay.Seconds())
-				select {
-				case <-c.ctx.Done():
-					return
-				case <-time.After(currentDelay):
-					// Exponential backoff: double the delay, capped at MaxReconnectDelay
-					currentDelay *= 2
-					if currentDelay > c.config.MaxReconnectDelay {
-						currentDelay = c.config.MaxReconnectDelay
-					}
-					continue
-				}
+		}
+
+		attempt++
+		if err := c.connect(ep); err != nil {
+			c.endpoints.failed(ep, err.Error())
+			delay := jitter(currentDelay, c.config.ReconnectJitter)
+			log.Printf("WebSocket connection to %s failed: %v (retrying in %.1f seconds)",
+				auth.RedactURL(ep.url), err, delay.Seconds())
+			event := ConnectionEvent{
+				Attempt:  attempt,
+				Delay:    delay,
+				Reason:   err.Error(),
+				Endpoint: auth.RedactURL(ep.url),
+			}
+			c.publishConnection(events.TypeReconnecting, event)
+			c.handler.HandleReconnecting(event)
+			if !c.sleep(delay) {
+				return
 			}
 
-			// Connection successful, reset backoff delay
-			currentDelay = c.config.ReconnectDelay
+			// Exponential backoff: double the delay, capped at MaxReconnectDelay
+			currentDelay *= 2
+			if currentDelay > c.config.MaxReconnectDelay {
+				currentDelay = c.config.MaxReconnectDelay
+			}
+			continue
+		}
+
+		// Connection successful, reset backoff delay
+		c.endpoints.succeeded(ep)
+		currentDelay = c.config.ReconnectDelay
+
+		// Start message loop (blocks until disconnected)
+		reason := c.messageLoop(attempt, ep)
+		attempt = 0
+		c.endpoints.disconnected()
+
+		if c.ctx.Err() != nil {
+			return
+		}
+		event := ConnectionEvent{Reason: reason.Error(), Endpoint: auth.RedactURL(ep.url)}
+		c.publishConnection(events.TypeDisconnected, event)
+		c.handler.HandleDisconnected(event)
 
-			// Start message loop (blocks until disconnected)
-			c.messageLoop()
+		if directive := c.takeDirective(); directive != nil {
+			c.applyDirective(ep, directive)
+		}
+
+		// Stagger the first reconnect so devices dropped together by a
+		// server restart do not all return at the same moment
+		if !c.sleep(c.config.ReconnectDelay - jitter(c.config.ReconnectDelay, c.config.ReconnectJitter)) {
+			return
 		}
 	}
 }
 
-// connect establishes a connection
-func (c *Client) connect() error {
-	dialer := websocket.DefaultDialer
-	dialer.HandshakeTimeout = c.config.WriteTimeout
+// sleep waits for d and reports false if the client stopped meanwhile
+func (c *Client) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return c.ctx.Err() == nil
+	}
+	select {
+	case <-c.ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// setDirective stores a server reconnect directive for the connect loop
+func (c *Client) setDirective(directive *serverDirective) {
+	c.mutex.Lock()
+	c.directive = directive
+	c.mutex.Unlock()
+}
+
+// takeDirective returns and clears the pending server directive
+func (c *Client) takeDirective() *serverDirective {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	directive := c.directive
+	c.directive = nil
+	return directive
+}
+
+// applyDirective parks the endpoint for the requested retry-after period
+// and moves to the redirect target if it is a configured endpoint
+func (c *Client) applyDirective(ep *endpoint, directive *serverDirective) {
+	log.Printf("Server requested reconnect (redirect: %q, retry after: %v, reason: %q)",
+		auth.RedactURL(directive.Redirect), directive.RetryAfter, directive.Reason)
 
-	conn, _, err := dialer.Dial(c.config.URL, nil)
+	if directive.RetryAfter > 0 {
+		c.endpoints.park(ep, spread(directive.RetryAfter, c.config.ReconnectJitter))
+	}
+	if directive.Redirect != "" && !c.endpoints.prefer(directive.Redirect) {
+		log.Printf("Ignoring redirect to unconfigured endpoint %s", auth.RedactURL(directive.Redirect))
+	}
+}
+
+// EndpointStatus returns the health of every configured endpoint
+func (c *Client) EndpointStatus() []EndpointStatus {
+	return c.endpoints.status()
+}
+
+// connect establishes a connection to the endpoint
+func (c *Client) connect(ep *endpoint) error {
+	conn, err := c.dial(ep)
 	if err != nil {
 		return err
 	}
 
+	// Use binary audio frames only if the server accepted the subprotocol
+	binaryMode := conn.Subprotocol() == BinarySubprotocol
+	c.binaryMode.Store(binaryMode)
+	c.uplinkSeq = 0
+	c.downlinkSeq = 0
+	if c.config.BinaryAudio && !binaryMode {
+		log.Println("Server did not accept binary audio framing, using JSON/base64 audio")
+	} else if binaryMode && c.enableDebug {
+		log.Println("Binary audio framing negotiated")
+	}
+
 	// Set connection parameters
 	conn.SetReadLimit(c.config.MaxMessageSize)
 	if err := conn.SetReadDeadline(time.Now().Add(c.config.ReadTimeout)); err != nil {
@@ -250,6 +532,7 @@ func (c *Client) connect() error {
 	c.mutex.Lock()
 	c.conn = conn
 	c.mutex.Unlock()
+	c.lastTraffic.Store(time.Now().UnixNano())
 
 	if c.enableDebug {
 		log.Println("WebSocket connected successfully")
@@ -257,9 +540,98 @@ func (c *Client) connect() error {
 	return nil
 }
 
-// messageLoop is the message loop
-func (c *Client) messageLoop() {
+// dial performs the WebSocket handshake with the endpoint
+func (c *Client) dial(ep *endpoint) (*websocket.Conn, error) {
+	// Copy the default dialer so the package-level instance is never mutated
+	dialer := *websocket.DefaultDialer
+	dialer.HandshakeTimeout = c.config.WriteTimeout
+	dialer.TLSClientConfig = c.tlsConfig
+	dialer.Proxy = c.proxyFunc
+	if c.config.BinaryAudio {
+		dialer.Subprotocols = []string{BinarySubprotocol}
+	}
+
+	dialURL, header, token, err := c.handshakeParams(ep.url)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.enableDebug {
+		log.Printf("Connecting to %s", auth.RedactURL(dialURL))
+	}
+
+	conn, resp, err := dialer.Dial(dialURL, header)
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			// The token was rejected; fetch a fresh one on the next attempt
+			if c.tokenProvider != nil {
+				c.tokenProvider.Invalidate()
+			}
+			return nil, fmt.Errorf("handshake rejected: %s", resp.Status)
+		}
+		if resp != nil {
+			// An overloaded or draining server may say when to come back
+			if retryAfter := parseRetryAfter(resp); retryAfter > 0 {
+				c.endpoints.park(ep, spread(retryAfter, c.config.ReconnectJitter))
+				return nil, fmt.Errorf("handshake rejected: %s (retry after %v)", resp.Status, retryAfter)
+			}
+		}
+		return nil, fmt.Errorf("%s", auth.Redact(err.Error(), token, c.config.Proxy.Password))
+	}
+	return conn, nil
+}
+
+// handshakeParams returns the URL and headers for the handshake. The token
+// goes in the Authorization header; it is only added to the URL when the
+// legacy query parameter mode is enabled.
+func (c *Client) handshakeParams(endpointURL string) (string, http.Header, string, error) {
+	header := http.Header{}
+	if c.tokenProvider == nil {
+		return endpointURL, header, "", nil
+	}
+
+	token, err := c.tokenProvider.Token(c.ctx)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("failed to obtain access token: %w", err)
+	}
+	header.Set("Authorization", "Bearer "+token)
+
+	dialURL := endpointURL
+	if c.config.TokenInQuery {
+		u, err := url.Parse(dialURL)
+		if err != nil {
+			return "", nil, "", fmt.Errorf("invalid websocket url: %s", auth.RedactURL(dialURL))
+		}
+		query := u.Query()
+		query.Set("token", token)
+		u.RawQuery = query.Encode()
+		dialURL = u.String()
+	}
+
+	return dialURL, header, token, nil
+}
+
+// messageLoop is the message loop. It returns the reason the connection ended.
+func (c *Client) messageLoop(attempt int, ep *endpoint) error {
+	c.mutex.RLock()
+	writerConn := c.conn
+	c.mutex.RUnlock()
+
+	// Start the single writer for this connection
+	writerDone := make(chan struct{})
+	c.queue.setOpen(true)
+	go c.writeLoop(writerConn, writerDone)
+
 	defer func() {
+		// Stop accepting messages and fail anything still queued
+		c.queue.setOpen(false)
+		close(writerDone)
+
+		// Cancels sent on this connection are not acknowledged on the next
+		c.pendingMutex.Lock()
+		clear(c.sentCancels)
+		c.pendingMutex.Unlock()
+
 		c.mutex.Lock()
 		if c.conn != nil {
 			if err := c.conn.Close(); err != nil {
@@ -275,33 +647,118 @@ func (c *Client) messageLoop() {
 
 	// Start ping goroutine
 	go c.pingLoop()
+	go c.failbackLoop(writerConn)
+
+	event := ConnectionEvent{Attempt: attempt, Endpoint: auth.RedactURL(ep.url)}
+	c.publishConnection(events.TypeConnected, event)
+	c.handler.HandleConnected(event)
 
 	for {
 		select {
 		case <-c.ctx.Done():
-			return
+			return c.ctx.Err()
 		default:
 			c.mutex.RLock()
 			conn := c.conn
 			c.mutex.RUnlock()
 
 			if conn == nil {
-				return
+				return fmt.Errorf("connection closed")
 			}
 
-			_, message, err := conn.ReadMessage()
+			msgType, message, err := conn.ReadMessage()
 			if err != nil {
 				log.Printf("WebSocket receive error: %v", err)
-				return
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) {
+					if directive := parseCloseReason(closeErr.Text); directive != nil {
+						c.setDirective(directive)
+					}
+				}
+				return err
 			}
 
-			if err := c.handleMessage(message); err != nil {
+			c.lastTraffic.Store(time.Now().UnixNano())
+			if c.capture != nil {
+				c.capture.record(CaptureInbound, msgType, message)
+			}
+
+			if msgType == websocket.BinaryMessage {
+				err = c.handleBinaryMessage(message)
+			} else {
+				err = c.handleMessage(message)
+			}
+			if errors.Is(err, errReconnectRequested) {
+				// The connect loop applies the stored directive
+				return err
+			}
+			if err != nil {
 				log.Printf("Failed to handle message: %v", err)
 			}
 		}
 	}
 }
 
+// failbackLoop returns to a higher-priority endpoint while connected to a
+// fallback. Every BreakerCooldown, once the connection has been idle for
+// failbackIdle, the best available endpoint is probed with a handshake; if
+// it answers, the connection is closed and the connect loop moves over.
+func (c *Client) failbackLoop(conn *websocket.Conn) {
+	if c.config.BreakerCooldown <= 0 || len(c.endpoints.endpoints) < 2 {
+		return
+	}
+	ticker := time.NewTicker(c.config.BreakerCooldown)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.mutex.RLock()
+			current := c.conn
+			c.mutex.RUnlock()
+			if current != conn {
+				return
+			}
+
+			target := c.endpoints.failbackTarget()
+			if target == nil || !c.idle() {
+				continue
+			}
+
+			probe, err := c.dial(target)
+			if err != nil {
+				c.endpoints.failed(target, err.Error())
+				if c.enableDebug {
+					log.Printf("Fail-back probe of %s failed: %v", auth.RedactURL(target.url), err)
+				}
+				continue
+			}
+			probe.Close()
+
+			log.Printf("Endpoint %s is reachable again, failing back to it", auth.RedactURL(target.url))
+			c.endpoints.prefer(target.url)
+			conn.Close() // The message loop ends and the connect loop reconnects
+			return
+		}
+	}
+}
+
+// idle reports whether nothing was sent or received for failbackIdle and no
+// message or request is outstanding, so switching endpoints interrupts nothing
+func (c *Client) idle() bool {
+	if time.Since(time.Unix(0, c.lastTraffic.Load())) < failbackIdle {
+		return false
+	}
+	if stats := c.queue.stats(); stats.QueuedControl > 0 || stats.QueuedAudio > 0 {
+		return false
+	}
+	c.pendingMutex.Lock()
+	defer c.pendingMutex.Unlock()
+	return len(c.pending) == 0
+}
+
 // pingLoop is the ping loop
 func (c *Client) pingLoop() {
 	ticker := time.NewTicker(c.config.PingInterval)
@@ -392,6 +849,7 @@ func (c *Client) handleMessage(message []byte) error {
 		if err := json.Unmarshal(message, &resp); err != nil {
 			return fmt.Errorf("failed to parse config update response: %w", err)
 		}
+		c.resolvePending(message)
 		c.handler.HandleUpdateConfig(&resp)
 
 	case "cancelOutput":
@@ -400,9 +858,26 @@ func (c *Client) handleMessage(message []byte) error {
 			return fmt.Errorf("failed to parse cancel output response: %w", err)
 		}
 		log.Printf("[WsClient] Received cancelOutput from server (type: %s)", resp.Data.CancelType)
+		// The acknowledgment of our own cancel goes to its caller only
+		if c.resolvePending(message) || c.ownCancelAck(&resp) {
+			break
+		}
 		c.handler.HandleCancelOutput(&resp)
 
+	case "reconnect":
+		var req ReconnectRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			return fmt.Errorf("failed to parse reconnect request: %w", err)
+		}
+		c.setDirective(&serverDirective{
+			Redirect:   req.Data.URL,
+			RetryAfter: time.Duration(req.Data.RetryAfter) * time.Second,
+			Reason:     req.Data.Reason,
+		})
+		return errReconnectRequested
+
 	case "clearContext":
+		c.resolvePending(message)
 		// Clear context acknowledged, no action needed
 		if c.enableDebug {
 			log.Println("Clear context acknowledged by server")
@@ -414,3 +889,30 @@ func (c *Client) handleMessage(message []byte) error {
 
 	return nil
 }
+
+// handleBinaryMessage handles a binary audio frame
+func (c *Client) handleBinaryMessage(message []byte) error {
+	frame, err := DecodeAudioFrame(message)
+	if err != nil {
+		return err
+	}
+
+	if frame.Action != FrameOutputAudioStream {
+		return fmt.Errorf("unexpected binary frame action: %s", frame.ActionName())
+	}
+
+	if c.enableDebug && c.downlinkSeq != 0 && frame.Sequence != c.downlinkSeq+1 {
+		log.Printf("Binary audio sequence gap: expected %d, got %d", c.downlinkSeq+1, frame.Sequence)
+	}
+	c.downlinkSeq = frame.Sequence
+
+	resp := OutputAudioStreamResponse{
+		ID:     frame.ID,
+		Action: "outputAudioStream",
+		PCM:    frame.PCM,
+	}
+	resp.Data.ChatID = frame.ChatID
+	resp.Data.ConversationID = frame.ConversationID
+	c.handler.HandleOutputAudioStream(&resp)
+	return nil
+}
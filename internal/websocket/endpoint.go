@@ -0,0 +1,289 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"websocket_client_chat/internal/auth"
+)
+
+// EndpointStatus is a snapshot of one endpoint's health
+type EndpointStatus struct {
+	URL         string    `json:"url"`
+	Active      bool      `json:"active"`              // Currently connected
+	Failures    int       `json:"failures"`            // Consecutive failed attempts
+	ParkedUntil time.Time `json:"parkedUntil"`         // Not tried before this time
+	LastError   string    `json:"lastError,omitempty"` // Most recent failure
+}
+
+// failbackIdle is how long a fallback connection must be quiet before the
+// client moves back to a higher-priority endpoint
+const failbackIdle = 10 * time.Second
+
+// endpoint tracks the health of one server URL
+type endpoint struct {
+	url         string
+	failures    int
+	parkedUntil time.Time
+	lastError   string
+}
+
+// endpointPool selects the endpoint for each connection attempt. Endpoints
+// are ordered by priority; the pool picks the available endpoint with the
+// fewest consecutive failures, ties going to the higher priority, so a
+// failing endpoint hands over to the next one. An endpoint that fails
+// breakerThreshold times in a row is parked for breakerCooldown (circuit
+// open) and then gets a single trial attempt (half-open), ahead of lower
+// priority endpoints, so the client returns to it once it recovers.
+type endpointPool struct {
+	endpoints []*endpoint
+	active    *endpoint
+	preferred *endpoint // Set by a server redirect, used for the next attempt
+
+	breakerThreshold int
+	breakerCooldown  time.Duration
+
+	mutex sync.Mutex
+}
+
+// newEndpointPool creates a pool for the given URLs in priority order
+func newEndpointPool(urls []string, breakerThreshold int, breakerCooldown time.Duration) *endpointPool {
+	p := &endpointPool{
+		breakerThreshold: breakerThreshold,
+		breakerCooldown:  breakerCooldown,
+	}
+	for _, u := range urls {
+		p.endpoints = append(p.endpoints, &endpoint{url: u})
+	}
+	return p
+}
+
+// next returns the endpoint to try and how long to wait before trying it.
+// The wait is non-zero only when every endpoint is parked.
+func (p *endpointPool) next() (*endpoint, time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	if p.preferred != nil {
+		ep := p.preferred
+		p.preferred = nil
+		if !now.Before(ep.parkedUntil) {
+			return ep, 0
+		}
+	}
+
+	var best, soonest *endpoint
+	for _, ep := range p.endpoints {
+		if now.Before(ep.parkedUntil) {
+			if soonest == nil || ep.parkedUntil.Before(soonest.parkedUntil) {
+				soonest = ep
+			}
+			continue
+		}
+		if best == nil || ep.failures < best.failures {
+			best = ep
+		}
+	}
+
+	if best != nil {
+		// A recovered higher-priority endpoint gets its trial first
+		for _, ep := range p.endpoints {
+			if ep == best {
+				break
+			}
+			if p.breakerThreshold > 0 && ep.failures >= p.breakerThreshold && !now.Before(ep.parkedUntil) {
+				return ep, 0
+			}
+		}
+		return best, 0
+	}
+	return soonest, soonest.parkedUntil.Sub(now)
+}
+
+// failbackTarget returns the highest-priority endpoint ranked above the
+// active one that is not parked, or nil if the active endpoint is the best
+// available
+func (p *endpointPool) failbackTarget() *endpoint {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.active == nil {
+		return nil
+	}
+	now := time.Now()
+	for _, ep := range p.endpoints {
+		if ep == p.active {
+			return nil
+		}
+		if !now.Before(ep.parkedUntil) {
+			return ep
+		}
+	}
+	return nil
+}
+
+// succeeded marks the endpoint healthy and active
+func (p *endpointPool) succeeded(ep *endpoint) {
+	p.mutex.Lock()
+	ep.failures = 0
+	ep.parkedUntil = time.Time{}
+	ep.lastError = ""
+	p.active = ep
+	p.mutex.Unlock()
+}
+
+// disconnected clears the active endpoint
+func (p *endpointPool) disconnected() {
+	p.mutex.Lock()
+	p.active = nil
+	p.mutex.Unlock()
+}
+
+// failed records a failed attempt and opens the circuit when the endpoint
+// keeps failing
+func (p *endpointPool) failed(ep *endpoint, reason string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ep.failures++
+	ep.lastError = reason
+	if p.breakerThreshold > 0 && ep.failures >= p.breakerThreshold {
+		ep.parkedUntil = time.Now().Add(p.breakerCooldown)
+		log.Printf("Endpoint %s failed %d times in a row, parked for %v",
+			auth.RedactURL(ep.url), ep.failures, p.breakerCooldown)
+	}
+}
+
+// park keeps the endpoint out of rotation for d, e.g. after a server retry-after
+func (p *endpointPool) park(ep *endpoint, d time.Duration) {
+	p.mutex.Lock()
+	if until := time.Now().Add(d); until.After(ep.parkedUntil) {
+		ep.parkedUntil = until
+	}
+	p.mutex.Unlock()
+}
+
+// prefer makes the endpoint with the given URL the next one tried. Only
+// configured endpoints are accepted so a server cannot send the device to
+// an arbitrary host.
+func (p *endpointPool) prefer(url string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, ep := range p.endpoints {
+		if ep.url == url || strings.TrimSuffix(ep.url, "/") == strings.TrimSuffix(url, "/") {
+			ep.parkedUntil = time.Time{}
+			p.preferred = ep
+			return true
+		}
+	}
+	return false
+}
+
+// status returns a snapshot of every endpoint
+func (p *endpointPool) status() []EndpointStatus {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	out := make([]EndpointStatus, 0, len(p.endpoints))
+	for _, ep := range p.endpoints {
+		out = append(out, EndpointStatus{
+			URL:         auth.RedactURL(ep.url),
+			Active:      ep == p.active,
+			Failures:    ep.failures,
+			ParkedUntil: ep.parkedUntil,
+			LastError:   ep.lastError,
+		})
+	}
+	return out
+}
+
+// errReconnectRequested ends the message loop after a "reconnect" message
+var errReconnectRequested = errors.New("server requested reconnect")
+
+// serverDirective is a reconnect instruction from the server, delivered as a
+// "reconnect" message, as JSON in a close frame's reason, or as a
+// Retry-After header on a rejected handshake
+type serverDirective struct {
+	Redirect   string // Endpoint URL to move to (must be configured)
+	RetryAfter time.Duration
+	Reason     string
+}
+
+// ReconnectRequest is a server message asking the client to reconnect
+type ReconnectRequest struct {
+	Action string `json:"action"`
+	Data   struct {
+		URL        string `json:"url,omitempty"`        // Endpoint to move to
+		RetryAfter int    `json:"retryAfter,omitempty"` // Seconds to stay away from the current endpoint
+		Reason     string `json:"reason,omitempty"`
+	} `json:"data"`
+}
+
+// closeDirective is the JSON form of a directive in a close frame reason
+type closeDirective struct {
+	URL        string `json:"url"`
+	RetryAfter int    `json:"retryAfter"`
+	Reason     string `json:"reason"`
+}
+
+// parseCloseReason extracts a directive from a close frame reason, or
+// returns nil if the reason is not a directive
+func parseCloseReason(text string) *serverDirective {
+	if !strings.HasPrefix(strings.TrimSpace(text), "{") {
+		return nil
+	}
+	var d closeDirective
+	if err := json.Unmarshal([]byte(text), &d); err != nil {
+		return nil
+	}
+	if d.URL == "" && d.RetryAfter <= 0 {
+		return nil
+	}
+	return &serverDirective{
+		Redirect:   d.URL,
+		RetryAfter: time.Duration(d.RetryAfter) * time.Second,
+		Reason:     d.Reason,
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// spread randomizes d upwards by up to fraction of its length, for waits the
+// server asked for and that must not end early
+func spread(d time.Duration, fraction float64) time.Duration {
+	return 2*d - jitter(d, fraction)
+}
+
+// jitter randomizes d downwards by up to fraction of its length so that
+// devices disconnected together do not reconnect in lockstep
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if d <= 0 || fraction <= 0 {
+		return d
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	return d - time.Duration(rand.Float64()*fraction*float64(d))
+}
@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// BinarySubprotocol is offered during the handshake when binary audio framing
+// is enabled. If the server selects it, audio travels as binary frames;
+// otherwise the client falls back to base64 audio inside JSON messages.
+const BinarySubprotocol = "lebot.audio.binary.v1"
+
+// frameVersion is the binary frame format version
+const frameVersion = 1
+
+// Binary frame action codes
+const (
+	FrameInputAudioStream   byte = 0x01
+	FrameInputWakeAudio     byte = 0x02
+	FrameInputAudioComplete byte = 0x03
+	FrameOutputAudioStream  byte = 0x81
+)
+
+// frameActions maps frame action codes to protocol action names
+var frameActions = map[byte]string{
+	FrameInputAudioStream:   "inputAudioStream",
+	FrameInputWakeAudio:     "inputWakeAudio",
+	FrameInputAudioComplete: "inputAudioComplete",
+	FrameOutputAudioStream:  "outputAudioStream",
+}
+
+// AudioFrame is a binary audio message.
+//
+// Layout (multi-byte integers big-endian):
+//
+//	[0]      version (1)
+//	[1]      action code
+//	[2:6]    sequence number (uint32, per connection and direction)
+//	[6]      request ID length N, followed by N bytes
+//	[..]     chat ID length M, followed by M bytes (empty on uplink)
+//	[..]     conversation ID length K, followed by K bytes (empty on uplink)
+//	[..]     audio payload: raw 16-bit little-endian PCM, or length-prefixed
+//	         codec packets when a compressed codec is configured
+type AudioFrame struct {
+	Action         byte
+	Sequence       uint32
+	ID             string
+	ChatID         string
+	ConversationID string
+	PCM            []byte
+}
+
+// ActionName returns the protocol action name for the frame's action code
+func (f *AudioFrame) ActionName() string {
+	if name, ok := frameActions[f.Action]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(0x%02x)", f.Action)
+}
+
+// EncodeAudioFrame serializes a frame
+func EncodeAudioFrame(f *AudioFrame) ([]byte, error) {
+	for _, s := range []string{f.ID, f.ChatID, f.ConversationID} {
+		if len(s) > 255 {
+			return nil, fmt.Errorf("frame identifier too long (%d bytes)", len(s))
+		}
+	}
+
+	size := 6 + 3 + len(f.ID) + len(f.ChatID) + len(f.ConversationID) + len(f.PCM)
+	buf := make([]byte, 0, size)
+	buf = append(buf, frameVersion, f.Action)
+	buf = binary.BigEndian.AppendUint32(buf, f.Sequence)
+	for _, s := range []string{f.ID, f.ChatID, f.ConversationID} {
+		buf = append(buf, byte(len(s)))
+		buf = append(buf, s...)
+	}
+	buf = append(buf, f.PCM...)
+	return buf, nil
+}
+
+// DecodeAudioFrame parses a frame. The returned PCM aliases data.
+func DecodeAudioFrame(data []byte) (*AudioFrame, error) {
+	if len(data) < 6 {
+		return nil, fmt.Errorf("binary frame too short (%d bytes)", len(data))
+	}
+	if data[0] != frameVersion {
+		return nil, fmt.Errorf("unsupported binary frame version %d", data[0])
+	}
+
+	f := &AudioFrame{
+		Action:   data[1],
+		Sequence: binary.BigEndian.Uint32(data[2:6]),
+	}
+
+	pos := 6
+	fields := []*string{&f.ID, &f.ChatID, &f.ConversationID}
+	for _, field := range fields {
+		if pos >= len(data) {
+			return nil, fmt.Errorf("binary frame truncated in header")
+		}
+		n := int(data[pos])
+		pos++
+		if pos+n > len(data) {
+			return nil, fmt.Errorf("binary frame truncated in header")
+		}
+		*field = string(data[pos : pos+n])
+		pos += n
+	}
+
+	f.PCM = data[pos:]
+	return f, nil
+}
+
+// stripWAVHeader returns the PCM payload of a canonical 44-byte-header WAV.
+// Compressed payloads are returned unchanged.
+func stripWAVHeader(wavData []byte) []byte {
+	if isWAV(wavData) {
+		return wavData[wavHeaderSize:]
+	}
+	return wavData
+}
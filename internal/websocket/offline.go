@@ -0,0 +1,131 @@
+package websocket
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// offlineEnabled reports whether audio is buffered while disconnected
+func (q *sendQueue) offlineEnabled() bool {
+	return q.offlineMaxBytes > 0
+}
+
+// holdOffline buffers an audio message while disconnected. When the byte
+// budget is exceeded, whole turns are discarded oldest first so that the
+// backend never receives a turn with a hole in it. If the oldest turn is
+// the message's own, that turn is discarded and the message and the rest
+// of the turn are dropped. Must be called with mutex held.
+func (q *sendQueue) holdOffline(msg *outbound) error {
+	if len(msg.audio) > q.offlineMaxBytes {
+		return fmt.Errorf("message exceeds offline buffer size: %w", errNotConnected)
+	}
+
+	var lost []string
+	for q.bufferedBytes()+len(msg.audio) > q.offlineMaxBytes && len(q.audio) > 0 {
+		requestID := q.audio[0].requestID
+		q.removeTurn(requestID)
+		q.lostTurn = requestID
+		lost = append(lost, requestID)
+		if requestID == msg.requestID {
+			break
+		}
+	}
+	if msg.requestID == q.lostTurn {
+		q.dropped.Add(1)
+	} else {
+		q.audio = append(q.audio, msg)
+	}
+
+	if len(lost) > 0 {
+		go q.reportLost(lost, "offline buffer full")
+	}
+	return nil
+}
+
+// expire discards every turn holding a message older than offlineMaxAge
+func (q *sendQueue) expire() {
+	if !q.offlineEnabled() || q.offlineMaxAge <= 0 {
+		return
+	}
+
+	q.mutex.Lock()
+	cutoff := time.Now().Add(-q.offlineMaxAge)
+	var lost []string
+	seen := make(map[string]bool)
+	for _, msg := range q.audio {
+		if msg.queuedAt.Before(cutoff) && !seen[msg.requestID] {
+			seen[msg.requestID] = true
+			lost = append(lost, msg.requestID)
+		}
+	}
+	for _, requestID := range lost {
+		q.removeTurn(requestID)
+		q.lostTurn = requestID
+	}
+	q.mutex.Unlock()
+
+	if len(lost) > 0 {
+		q.reportLost(lost, fmt.Sprintf("not sent within %v", q.offlineMaxAge))
+	}
+}
+
+// removeTurn drops all queued audio for a request. Must be called with mutex held.
+func (q *sendQueue) removeTurn(requestID string) {
+	kept := q.audio[:0]
+	removed := 0
+	for _, msg := range q.audio {
+		if msg.requestID == requestID {
+			removed++
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	for i := len(kept); i < len(q.audio); i++ {
+		q.audio[i] = nil
+	}
+	q.audio = kept
+	q.dropped.Add(uint64(removed))
+}
+
+// bufferedBytes returns the total payload size of queued audio. Must be called with mutex held.
+func (q *sendQueue) bufferedBytes() int {
+	total := 0
+	for _, msg := range q.audio {
+		total += len(msg.audio)
+	}
+	return total
+}
+
+// reportLost counts and reports discarded turns
+func (q *sendQueue) reportLost(requestIDs []string, reason string) {
+	for _, requestID := range requestIDs {
+		q.lostTurns.Add(1)
+		log.Printf("Discarded buffered turn %s: %s", requestID, reason)
+		if q.onLost != nil {
+			q.onLost(requestID, reason)
+		}
+	}
+}
+
+// offlineExpiryLoop expires buffered turns while the connection is down so
+// the application learns about lost turns without waiting for a reconnect
+func (c *Client) offlineExpiryLoop() {
+	if !c.queue.offlineEnabled() || c.queue.offlineMaxAge <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			if !c.IsConnected() {
+				c.queue.expire()
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"websocket_client_chat/internal/config"
+)
+
+// proxyEnv lists the environment variables consulted when no proxy URL is
+// configured, per handshake scheme, in order of precedence. The dialer
+// presents ws:// and wss:// targets as http and https.
+var proxyEnv = map[string][]string{
+	"https": {"HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy"},
+	"http":  {"HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"},
+}
+
+// BuildProxyFunc validates the proxy settings and returns the dialer's proxy
+// function. The configured URL takes precedence over the environment; it
+// returns nil when no proxy is configured.
+func BuildProxyFunc(cfg *config.ProxyConfig) (func(*http.Request) (*url.URL, error), error) {
+	proxies := make(map[string]*url.URL)
+	for scheme, keys := range proxyEnv {
+		proxyRaw := cfg.URL
+		if proxyRaw == "" {
+			proxyRaw = lookupEnv(keys...)
+		}
+		if proxyRaw == "" {
+			continue
+		}
+
+		proxyURL, err := parseProxyURL(proxyRaw)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Username != "" {
+			proxyURL.User = url.UserPassword(cfg.Username, cfg.Password)
+		}
+		proxies[scheme] = proxyURL
+	}
+	if len(proxies) == 0 {
+		return nil, nil
+	}
+
+	noProxy := cfg.NoProxy
+	if len(noProxy) == 0 {
+		noProxy = strings.Split(lookupEnv("NO_PROXY", "no_proxy"), ",")
+	}
+	bypass := newNoProxyMatcher(noProxy)
+
+	return func(req *http.Request) (*url.URL, error) {
+		if bypass.match(req.URL.Hostname()) {
+			return nil, nil
+		}
+		return proxies[req.URL.Scheme], nil
+	}, nil
+}
+
+// parseProxyURL parses and validates a proxy URL. Only HTTP CONNECT and
+// SOCKS5 proxies are supported by the dialer; a bare host:port means HTTP.
+func parseProxyURL(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+
+	switch u.Scheme {
+	case "http", "socks5":
+	case "socks5h":
+		// Host names are always resolved by the SOCKS5 proxy
+		u.Scheme = "socks5"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (use http or socks5)", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy url has no host")
+	}
+	return u, nil
+}
+
+// lookupEnv returns the first non-empty environment variable
+func lookupEnv(keys ...string) string {
+	for _, key := range keys {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// noProxyMatcher decides which hosts bypass the proxy
+type noProxyMatcher struct {
+	all      bool
+	domains  []string // Lowercase, without leading dot; subdomains match too
+	ips      []net.IP
+	networks []*net.IPNet
+}
+
+// newNoProxyMatcher parses NO_PROXY style entries: "*", host names, domain
+// suffixes (".example.com"), IP addresses and CIDR ranges. Ports are ignored.
+func newNoProxyMatcher(entries []string) *noProxyMatcher {
+	m := &noProxyMatcher{}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			m.all = true
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			m.networks = append(m.networks, network)
+			continue
+		}
+		if host, _, err := net.SplitHostPort(entry); err == nil {
+			entry = host
+		}
+		if ip := net.ParseIP(strings.Trim(entry, "[]")); ip != nil {
+			m.ips = append(m.ips, ip)
+			continue
+		}
+		m.domains = append(m.domains, strings.TrimPrefix(entry, "."))
+	}
+	return m
+}
+
+// match reports whether host bypasses the proxy
+func (m *noProxyMatcher) match(host string) bool {
+	if m.all {
+		return true
+	}
+	host = strings.ToLower(host)
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, candidate := range m.ips {
+			if candidate.Equal(ip) {
+				return true
+			}
+		}
+		for _, network := range m.networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, domain := range m.domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,263 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"websocket_client_chat/internal/config"
+)
+
+// ErrRequestTimeout is returned when no response arrived within the deadline
+var ErrRequestTimeout = errors.New("request timed out")
+
+// errSuperseded completes a future replaced by a newer request with the same key
+var errSuperseded = errors.New("superseded by a newer request with the same id")
+
+// ServerError is returned when the server answers a request with success: false
+type ServerError struct {
+	Action    string
+	RequestID string
+	Message   string
+}
+
+// Error implements the error interface
+func (e *ServerError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s rejected by server", e.Action, e.RequestID)
+	}
+	return fmt.Sprintf("%s %s rejected by server: %s", e.Action, e.RequestID, e.Message)
+}
+
+// RetryPolicy controls how a request is retried
+type RetryPolicy struct {
+	Timeout  time.Duration // Deadline for each attempt
+	Attempts int           // Total attempts, at least 1
+	Backoff  time.Duration // Delay before the second attempt, doubled for each further one
+}
+
+// DefaultRetryPolicy returns the retry policy from the configuration
+func DefaultRetryPolicy(cfg *config.WebSocketConfig) RetryPolicy {
+	return RetryPolicy{
+		Timeout:  cfg.RequestTimeout,
+		Attempts: cfg.RequestAttempts,
+		Backoff:  cfg.RequestBackoff,
+	}
+}
+
+// Response is a server reply to an acknowledged request
+type Response struct {
+	ID      string `json:"id"`
+	Action  string `json:"action"`
+	Success *bool  `json:"success"` // Missing is treated as success
+	Message string `json:"message"`
+
+	// Raw holds the full message for decoding action-specific data
+	Raw json.RawMessage `json:"-"`
+}
+
+// pendingKey identifies an outstanding request. The action is part of the
+// key because a session reuses its request ID across actions.
+type pendingKey struct {
+	action    string
+	requestID string
+}
+
+// Future is the pending result of a request
+type Future struct {
+	Action    string
+	RequestID string
+
+	done chan struct{}
+	once sync.Once
+	resp *Response
+	err  error
+}
+
+// newFuture creates an unresolved future
+func newFuture(action, requestID string) *Future {
+	return &Future{Action: action, RequestID: requestID, done: make(chan struct{})}
+}
+
+// Done is closed when the future resolves
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the future resolves or ctx ends
+func (f *Future) Wait(ctx context.Context) (*Response, error) {
+	select {
+	case <-f.done:
+		return f.resp, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// complete resolves the future; later calls are ignored
+func (f *Future) complete(resp *Response, err error) {
+	f.once.Do(func() {
+		f.resp = resp
+		f.err = err
+		close(f.done)
+	})
+}
+
+// Request sends an acknowledged control message and returns a future that
+// resolves on the response with the same action and request ID. Attempts
+// that fail to send or see no response within the policy's timeout are
+// retried with the same request ID. A success: false response resolves the
+// future with a *ServerError and is not retried.
+func (c *Client) Request(ctx context.Context, action, requestID string, message interface{}, policy RetryPolicy) *Future {
+	f := newFuture(action, requestID)
+	key := pendingKey{action: action, requestID: requestID}
+
+	c.pendingMutex.Lock()
+	if old, ok := c.pending[key]; ok {
+		old.complete(nil, errSuperseded)
+	}
+	c.pending[key] = f
+	c.pendingMutex.Unlock()
+
+	go func() {
+		defer c.removePending(key, f)
+
+		attempts := policy.Attempts
+		if attempts < 1 {
+			attempts = 1
+		}
+		backoff := policy.Backoff
+
+		for attempt := 1; ; attempt++ {
+			err := c.enqueueAndWait(&outbound{lane: laneControl, action: action, requestID: requestID, message: message})
+			if err == nil {
+				err = c.awaitAttempt(ctx, f, policy.Timeout)
+				if err == nil {
+					return
+				}
+			}
+
+			if ctx.Err() != nil || c.ctx.Err() != nil || attempt >= attempts {
+				f.complete(nil, fmt.Errorf("%s %s failed after %d attempt(s): %w", action, requestID, attempt, err))
+				return
+			}
+
+			log.Printf("[WsClient] %s %s attempt %d failed (%v), retrying in %v", action, requestID, attempt, err, backoff)
+			select {
+			case <-f.done:
+				return
+			case <-ctx.Done():
+			case <-c.ctx.Done():
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+	}()
+
+	return f
+}
+
+// awaitAttempt waits for the future to resolve within one attempt's timeout.
+// It returns nil once the future is resolved.
+func (c *Client) awaitAttempt(ctx context.Context, f *Future, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-f.done:
+		return nil
+	case <-timer.C:
+		return ErrRequestTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
+// removePending drops the future from the pending table unless a newer
+// request has replaced it
+func (c *Client) removePending(key pendingKey, f *Future) {
+	c.pendingMutex.Lock()
+	if c.pending[key] == f {
+		delete(c.pending, key)
+	}
+	c.pendingMutex.Unlock()
+}
+
+// resolvePending completes the future waiting for this response. Responses
+// without a matching request (late replies, server-initiated messages) are
+// ignored here. It reports whether a request was waiting for the response.
+func (c *Client) resolvePending(message []byte) bool {
+	var resp Response
+	if err := json.Unmarshal(message, &resp); err != nil {
+		return false
+	}
+
+	key := pendingKey{action: resp.Action, requestID: resp.ID}
+	c.pendingMutex.Lock()
+	f, ok := c.pending[key]
+	c.pendingMutex.Unlock()
+	if !ok {
+		if c.enableDebug {
+			log.Printf("No pending request for %s %s", resp.Action, resp.ID)
+		}
+		return false
+	}
+
+	resp.Raw = append(json.RawMessage(nil), message...)
+	if resp.Success != nil && !*resp.Success {
+		f.complete(&resp, &ServerError{Action: resp.Action, RequestID: resp.ID, Message: resp.Message})
+		return true
+	}
+	f.complete(&resp, nil)
+	return true
+}
+
+// UpdateConfig sends an update config request and waits for the server's
+// acknowledgment using the default retry policy. An empty conversationID
+// starts a new conversation.
+func (c *Client) UpdateConfig(ctx context.Context, requestID string, deviceConfig *config.DeviceConfig, conversationID string) (*UpdateConfigResponse, error) {
+	msg := buildUpdateConfigRequest(requestID, deviceConfig, conversationID)
+	resp, err := c.Request(ctx, msg.Action, requestID, msg, DefaultRetryPolicy(c.config)).Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result UpdateConfigResponse
+	if err := json.Unmarshal(resp.Raw, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse config update response: %w", err)
+	}
+	return &result, nil
+}
+
+// CancelOutput sends a cancel output request and waits for the server's
+// acknowledgment using the default retry policy
+func (c *Client) CancelOutput(ctx context.Context, requestID string) (*CancelOutputResponse, error) {
+	msg := CancelOutputRequest{ID: requestID, Action: "cancelOutput"}
+	resp, err := c.Request(ctx, msg.Action, requestID, msg, DefaultRetryPolicy(c.config)).Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result CancelOutputResponse
+	if err := json.Unmarshal(resp.Raw, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse cancel output response: %w", err)
+	}
+	return &result, nil
+}
+
+// ClearContext sends a clear context request and waits for the server's
+// acknowledgment using the default retry policy
+func (c *Client) ClearContext(ctx context.Context, requestID string) error {
+	msg := ClearContextRequest{ID: requestID, Action: "clearContext"}
+	_, err := c.Request(ctx, msg.Action, requestID, msg, DefaultRetryPolicy(c.config)).Wait(ctx)
+	return err
+}
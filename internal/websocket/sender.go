@@ -0,0 +1,427 @@
+package websocket
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"websocket_client_chat/internal/config"
+
+	"github.com/gorilla/websocket"
+)
+
+// Overflow policies for the audio lane
+const (
+	OverflowDropOldest = "dropOldest" // Drop the oldest queued inputAudioStream chunk
+	OverflowCoalesce   = "coalesce"   // Merge the two oldest chunks of the same request, drop if impossible
+)
+
+// controlQueueSize bounds the control lane; control messages are rare
+const controlQueueSize = 16
+
+// maxCoalescedBytes caps the payload size produced by coalescing (~2s of 16 kHz mono PCM)
+const maxCoalescedBytes = 64 * 1024
+
+// wavHeaderSize is the size of the canonical header written by utils.GenerateWAVHeader
+const wavHeaderSize = 44
+
+// errNotConnected is returned when a message cannot be queued or was discarded on disconnect
+var errNotConnected = errors.New("websocket not connected")
+
+// lane is a send priority lane
+type lane int
+
+const (
+	laneControl lane = iota // updateConfig, cancelOutput, clearContext
+	laneAudio               // inputAudioStream, inputWakeAudio, inputAudioComplete
+)
+
+// outbound is a queued uplink message. Audio messages keep their raw WAV
+// payload until write time so that queued chunks can still be coalesced.
+type outbound struct {
+	lane      lane
+	action    string
+	requestID string
+	audio     []byte      // WAV payload for audio actions
+	message   interface{} // Pre-built message for everything else
+	result    chan error  // Optional, receives the write result
+	queuedAt  time.Time   // When the message entered the queue
+}
+
+// SendQueueStats is a snapshot of the uplink queue counters
+type SendQueueStats struct {
+	QueuedControl int    // Control messages currently queued
+	QueuedAudio   int    // Audio messages currently queued
+	Sent          uint64 // Messages written to the connection
+	DroppedAudio  uint64 // Audio chunks dropped on overflow or disconnect
+	Coalesced     uint64 // Audio chunks merged into a neighbour on overflow
+	LostTurns     uint64 // Turns discarded from the offline buffer
+}
+
+// sendQueue is the bounded two-lane uplink queue. Control messages always
+// leave before audio; within a lane ordering is strict FIFO, which keeps
+// every request's audio in capture order.
+type sendQueue struct {
+	control []*outbound
+	audio   []*outbound
+
+	audioLimit int
+	policy     string
+	open       bool // False while disconnected; audio is held offline or rejected
+	mutex      sync.Mutex
+	notify     chan struct{}
+
+	// Offline buffering, disabled when offlineMaxBytes is zero
+	offlineMaxBytes int
+	offlineMaxAge   time.Duration
+	onLost          func(requestID string, reason string) // Called without the mutex held
+	lostTurn        string                                // Last discarded turn; its later chunks are dropped
+
+	sent      atomic.Uint64
+	dropped   atomic.Uint64
+	coalesced atomic.Uint64
+	lostTurns atomic.Uint64
+}
+
+// newSendQueue creates a send queue from the WebSocket configuration
+func newSendQueue(cfg *config.WebSocketConfig) *sendQueue {
+	audioLimit := cfg.SendQueueSize
+	if audioLimit <= 0 {
+		audioLimit = 50
+	}
+	policy := cfg.SendOverflowPolicy
+	if policy == "" {
+		policy = OverflowDropOldest
+	}
+	return &sendQueue{
+		audioLimit:      audioLimit,
+		policy:          policy,
+		notify:          make(chan struct{}, 1),
+		offlineMaxBytes: cfg.OfflineBufferBytes,
+		offlineMaxAge:   cfg.OfflineBufferMaxAge,
+	}
+}
+
+// push queues a message, applying the overflow policy to the audio lane
+func (q *sendQueue) push(msg *outbound) error {
+	msg.queuedAt = time.Now()
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	// The head of this turn was discarded, the rest must not be sent
+	if msg.lane == laneAudio && msg.requestID != "" && msg.requestID == q.lostTurn {
+		q.dropped.Add(1)
+		return nil
+	}
+
+	if !q.open {
+		if msg.lane == laneAudio && q.offlineEnabled() {
+			return q.holdOffline(msg)
+		}
+		return errNotConnected
+	}
+
+	switch msg.lane {
+	case laneControl:
+		if len(q.control) >= controlQueueSize {
+			return fmt.Errorf("control send queue full")
+		}
+		q.control = append(q.control, msg)
+	default:
+		if len(q.audio) >= q.audioLimit && !q.makeRoom() {
+			return fmt.Errorf("audio send queue full")
+		}
+		q.audio = append(q.audio, msg)
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// makeRoom frees one audio slot according to the overflow policy.
+// Only inputAudioStream chunks are considered stale; wake audio and
+// completion markers are never dropped. Must be called with mutex held.
+func (q *sendQueue) makeRoom() bool {
+	if q.policy == OverflowCoalesce {
+		for i := 0; i+1 < len(q.audio); i++ {
+			a, b := q.audio[i], q.audio[i+1]
+			if a.action != "inputAudioStream" || b.action != "inputAudioStream" || a.requestID != b.requestID {
+				continue
+			}
+			// Only WAV payloads can be merged; compressed audio is dropped instead
+			if !isWAV(a.audio) || !isWAV(b.audio) {
+				continue
+			}
+			if len(a.audio)+len(b.audio)-wavHeaderSize > maxCoalescedBytes {
+				continue
+			}
+			a.audio = appendWAV(a.audio, b.audio)
+			q.audio = append(q.audio[:i+1], q.audio[i+2:]...)
+			q.coalesced.Add(1)
+			return true
+		}
+	}
+
+	for i, msg := range q.audio {
+		if msg.action != "inputAudioStream" {
+			continue
+		}
+		q.audio = append(q.audio[:i], q.audio[i+1:]...)
+		if n := q.dropped.Add(1); n == 1 || n%50 == 0 {
+			log.Printf("Uplink queue overflow, dropped stale audio chunk (request %s, total dropped: %d)", msg.requestID, n)
+		}
+		return true
+	}
+	return false
+}
+
+// pop removes the next message, control lane first. Returns nil if empty.
+func (q *sendQueue) pop() *outbound {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.control) > 0 {
+		msg := q.control[0]
+		q.control[0] = nil
+		q.control = q.control[1:]
+		return msg
+	}
+	if len(q.audio) > 0 {
+		msg := q.audio[0]
+		q.audio[0] = nil
+		q.audio = q.audio[1:]
+		return msg
+	}
+	return nil
+}
+
+// requeueFront puts a message back at the head of its lane
+func (q *sendQueue) requeueFront(msg *outbound) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.audio = append([]*outbound{msg}, q.audio...)
+}
+
+// setOpen opens or closes the queue. Closing fails any waiting control
+// senders; queued audio is kept for replay when offline buffering is
+// enabled and discarded otherwise. Opening first expires stale turns so
+// only audio still worth sending is replayed.
+func (q *sendQueue) setOpen(open bool) {
+	if open {
+		q.expire()
+	}
+
+	q.mutex.Lock()
+	q.open = open
+	var discarded []*outbound
+	if !open {
+		discarded = q.control
+		q.control = nil
+		if !q.offlineEnabled() {
+			discarded = append(discarded, q.audio...)
+			q.audio = nil
+		} else if len(q.audio) > 0 {
+			log.Printf("Holding %d queued audio messages for replay after reconnect", len(q.audio))
+		}
+	} else if len(q.audio) > 0 {
+		log.Printf("Replaying %d buffered audio messages", len(q.audio))
+		select {
+		case q.notify <- struct{}{}:
+		default:
+		}
+	}
+	q.mutex.Unlock()
+
+	audioDropped := 0
+	for _, msg := range discarded {
+		if msg.lane == laneAudio {
+			audioDropped++
+		}
+		if msg.result != nil {
+			msg.result <- errNotConnected
+		}
+	}
+	if audioDropped > 0 {
+		q.dropped.Add(uint64(audioDropped))
+		log.Printf("Discarded %d queued audio messages on disconnect", audioDropped)
+	}
+}
+
+// stats returns a snapshot of the queue counters
+func (q *sendQueue) stats() SendQueueStats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return SendQueueStats{
+		QueuedControl: len(q.control),
+		QueuedAudio:   len(q.audio),
+		Sent:          q.sent.Load(),
+		DroppedAudio:  q.dropped.Load(),
+		Coalesced:     q.coalesced.Load(),
+		LostTurns:     q.lostTurns.Load(),
+	}
+}
+
+// isWAV reports whether data starts with a RIFF/WAVE header
+func isWAV(data []byte) bool {
+	return len(data) >= wavHeaderSize && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+// appendWAV appends the PCM payload of b to the WAV a and fixes up the header sizes
+func appendWAV(a, b []byte) []byte {
+	if len(a) < wavHeaderSize || len(b) < wavHeaderSize {
+		return a
+	}
+	merged := make([]byte, 0, len(a)+len(b)-wavHeaderSize)
+	merged = append(merged, a...)
+	merged = append(merged, b[wavHeaderSize:]...)
+
+	dataSize := len(merged) - wavHeaderSize
+	binary.LittleEndian.PutUint32(merged[4:8], uint32(dataSize+36))
+	binary.LittleEndian.PutUint32(merged[40:44], uint32(dataSize))
+	return merged
+}
+
+// writeLoop is the single writer for one connection. It drains the send
+// queue in priority order until the connection is closed.
+func (c *Client) writeLoop(conn *websocket.Conn, done <-chan struct{}) {
+	for {
+		msg := c.queue.pop()
+		if msg == nil {
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-done:
+				return
+			case <-c.queue.notify:
+				continue
+			}
+		}
+
+		err := c.writeOutbound(conn, msg)
+		if err != nil && msg.lane == laneAudio && c.queue.offlineEnabled() {
+			// Keep the chunk at the head of the queue so it is replayed in order
+			c.queue.requeueFront(msg)
+		} else if msg.result != nil {
+			msg.result <- err
+		}
+		if err != nil {
+			log.Printf("Failed to send %s: %v", msg.action, err)
+			// A failed write means the connection is unusable; unblock the reader
+			_ = conn.Close()
+			return
+		}
+		c.queue.sent.Add(1)
+		c.lastTraffic.Store(time.Now().UnixNano())
+	}
+}
+
+// writeOutbound encodes and writes a single message. Audio goes out as a
+// binary frame when the connection negotiated binary framing.
+func (c *Client) writeOutbound(conn *websocket.Conn, msg *outbound) error {
+	if msg.message != nil {
+		return c.writeJSON(conn, msg.message)
+	}
+	if c.binaryMode.Load() {
+		return c.writeAudioFrame(conn, msg)
+	}
+	return c.writeJSON(conn, buildAudioRequest(msg.action, msg.requestID, msg.audio))
+}
+
+// writeAudioFrame writes an audio message as a binary frame with raw PCM
+func (c *Client) writeAudioFrame(conn *websocket.Conn, msg *outbound) error {
+	action := FrameInputAudioStream
+	switch msg.action {
+	case "inputWakeAudio":
+		action = FrameInputWakeAudio
+	case "inputAudioComplete":
+		action = FrameInputAudioComplete
+	}
+
+	c.uplinkSeq++
+	data, err := EncodeAudioFrame(&AudioFrame{
+		Action:   action,
+		Sequence: c.uplinkSeq,
+		ID:       msg.requestID,
+		PCM:      stripWAVHeader(msg.audio),
+	})
+	if err != nil {
+		return err
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout)); err != nil {
+		return fmt.Errorf("failed to set write deadline: %w", err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		return fmt.Errorf("failed to send binary frame: %w", err)
+	}
+
+	if c.capture != nil {
+		c.capture.record(CaptureOutbound, websocket.BinaryMessage, data)
+	}
+	return nil
+}
+
+// buildAudioRequest builds the JSON request for an audio action
+func buildAudioRequest(action, requestID string, wavData []byte) interface{} {
+	switch action {
+	case "inputWakeAudio":
+		req := InputWakeAudioRequest{ID: requestID, Action: action}
+		req.Data.Buffer = base64.StdEncoding.EncodeToString(wavData)
+		return req
+	case "inputAudioComplete":
+		req := InputAudioCompleteRequest{ID: requestID, Action: action}
+		if len(wavData) > 0 {
+			req.Data.Buffer = base64.StdEncoding.EncodeToString(wavData)
+		}
+		return req
+	default:
+		req := InputAudioStreamRequest{ID: requestID, Action: action}
+		req.Data.Buffer = base64.StdEncoding.EncodeToString(wavData)
+		return req
+	}
+}
+
+// enqueue queues a message without waiting for it to be written
+func (c *Client) enqueue(msg *outbound) error {
+	return c.queue.push(msg)
+}
+
+// enqueueAndWait queues a message and waits until it has been written,
+// the connection drops, or the client is stopped
+func (c *Client) enqueueAndWait(msg *outbound) error {
+	msg.result = make(chan error, 1)
+	if err := c.queue.push(msg); err != nil {
+		return err
+	}
+
+	timeout := c.config.WriteTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	select {
+	case err := <-msg.result:
+		return err
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	case <-time.After(2 * timeout):
+		return fmt.Errorf("timed out waiting to send %s", msg.action)
+	}
+}
+
+// SendQueueStats returns the current uplink queue counters
+func (c *Client) SendQueueStats() SendQueueStats {
+	return c.queue.stats()
+}
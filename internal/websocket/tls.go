@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"websocket_client_chat/internal/config"
+)
+
+// tlsVersions maps configured minimum versions to TLS constants
+var tlsVersions = map[string]uint16{
+	"":    tls.VersionTLS12,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// BuildTLSConfig validates the TLS options and returns the dialer TLS
+// configuration. It returns nil for ws:// URLs without TLS options.
+func BuildTLSConfig(rawURL string, cfg *config.TLSConfig) (*tls.Config, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid websocket url: %w", err)
+	}
+
+	secure := u.Scheme == "wss"
+	if !secure {
+		if cfg.Enabled() {
+			return nil, fmt.Errorf("TLS options are configured but the URL scheme is %q, not wss", u.Scheme)
+		}
+		return nil, nil
+	}
+
+	minVersion, ok := tlsVersions[cfg.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS minimum version %q (use 1.2 or 1.3)", cfg.MinVersion)
+	}
+
+	// ServerName is left empty; the dialer fills it from the URL host
+	tlsConfig := &tls.Config{MinVersion: minVersion}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate requires both cert file and key file")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(cfg.Pins) > 0 {
+		pins, err := parsePins(cfg.Pins)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPins(cs, pins)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// parsePins decodes base64 SHA-256 SPKI hashes, optionally prefixed with "sha256/"
+func parsePins(raw []string) (map[[sha256.Size]byte]struct{}, error) {
+	pins := make(map[[sha256.Size]byte]struct{}, len(raw))
+	for _, pin := range raw {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(pin), "sha256/"))
+		if err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("invalid TLS pin %q (expected base64 SHA-256 of the SPKI)", pin)
+		}
+		var key [sha256.Size]byte
+		copy(key[:], decoded)
+		pins[key] = struct{}{}
+	}
+	return pins, nil
+}
+
+// verifyPins accepts the connection if any certificate in a verified chain
+// has a pinned public key. It runs after normal chain verification.
+func verifyPins(cs tls.ConnectionState, pins map[[sha256.Size]byte]struct{}) error {
+	for _, chain := range cs.VerifiedChains {
+		for _, cert := range chain {
+			if _, ok := pins[sha256.Sum256(cert.RawSubjectPublicKeyInfo)]; ok {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("server certificate chain does not match any pinned public key")
+}
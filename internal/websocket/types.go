@@ -1,5 +1,7 @@
 package websocket
 
+import "encoding/base64"
+
 // InputAudioStreamRequest is the input audio stream request
 type InputAudioStreamRequest struct {
 	ID     string `json:"id"`
@@ -37,6 +39,18 @@ type OutputAudioStreamResponse struct {
 		ConversationID string `json:"conversationId"`
 		Buffer         string `json:"buffer"`
 	} `json:"data"`
+
+	// PCM holds the raw audio when it arrived in a binary frame
+	PCM []byte `json:"-"`
+}
+
+// Audio returns the raw PCM payload, decoding the base64 buffer if the
+// message arrived as JSON
+func (r *OutputAudioStreamResponse) Audio() ([]byte, error) {
+	if r.PCM != nil {
+		return r.PCM, nil
+	}
+	return base64.StdEncoding.DecodeString(r.Data.Buffer)
 }
 
 // OutputAudioCompleteResponse is the output audio complete response
@@ -140,7 +154,8 @@ type UpdateConfigRequest struct {
 			Latitude  float64 `json:"latitude"`
 			Longitude float64 `json:"longitude"`
 		} `json:"location"`
-		Timezone string `json:"timezone,omitempty"`
+		Timezone   string `json:"timezone,omitempty"`
+		AudioCodec string `json:"audioCodec,omitempty"` // Uplink and downlink audio codec, omitted for the default WAV/PCM
 	} `json:"data"`
 }
 
@@ -0,0 +1,436 @@
+// Package aec provides a software acoustic echo canceller.
+//
+// The far-end signal (the samples handed to the loudspeaker) is pushed as
+// the playback callback consumes it, the near-end signal (the microphone)
+// is processed in chunks. A cross-correlation delay estimate aligns the two
+// streams and a normalized least mean squares (NLMS) filter models the echo
+// path after that delay and subtracts the estimated echo.
+package aec
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Processing parameters
+const (
+	blockDuration   = 0.010 // Double-talk decisions are made per block (seconds)
+	farQueueLimit   = 1.0   // Far-end audio kept while no near-end audio is processed (seconds)
+	defaultStepSize = 0.5
+	regularizeRMS   = 100.0 // Far-end level below which the filter barely adapts
+
+	convergedERLE    = 6.0  // Smoothed ERLE (dB) from which double talk is detected
+	doubleTalkMargin = 6.0  // A block ERLE this far below the smoothed ERLE is near-end speech
+	doubleTalkHold   = 0.05 // Adaptation stays frozen this long after double talk (seconds)
+	doubleTalkLimit  = 1.5  // Longer double talk is taken as a changed echo path (seconds)
+	divergedERLE     = -3.0 // Block ERLE (dB) below which the filter makes things worse
+	divergedLimit    = 0.1  // Time with a negative block ERLE after which the filter is reset (seconds)
+	erleSmoothing    = 0.05
+	minEstimationRMS = 100.0 // Far and near level needed for a delay estimate
+)
+
+// Config is the echo canceller configuration
+type Config struct {
+	SampleRate   int           // Sample rate of both streams in Hz
+	FilterLength time.Duration // Echo tail modelled by the adaptive filter (e.g. 64ms)
+	MaxDelay     time.Duration // Largest playback-to-capture delay searched (e.g. 500ms)
+	StepSize     float64       // NLMS step size (0-1], 0 uses the default
+}
+
+// Stats describes the canceller's current state
+type Stats struct {
+	Active      bool    `json:"active"`      // Far-end audio is being cancelled
+	DelayLocked bool    `json:"delayLocked"` // A playback-to-capture delay was estimated
+	DelayMs     float64 `json:"delayMs"`     // Estimated playback-to-capture delay
+	ERLE        float64 `json:"erleDb"`      // Smoothed echo return loss enhancement
+	Converged   bool    `json:"converged"`
+	DoubleTalk  bool    `json:"doubleTalk"` // Near-end speech during playback, adaptation frozen
+	Resets      int     `json:"resets"`     // Filter resets after delay changes or divergence
+}
+
+// Canceller removes the far-end echo from near-end audio. PushFarEnd and
+// Process may be called from different goroutines; Process itself must not
+// be called concurrently.
+type Canceller struct {
+	sampleRate int
+	taps       int
+	maxDelay   int
+	block      int
+	stepSize   float64
+	eps        float64 // NLMS regularization
+
+	// Far-end samples pushed but not yet aligned with near-end audio
+	farMutex sync.Mutex
+	farQueue []int16
+	farBlock int  // Largest block pushed, kept queued to absorb callback jitter
+	starved  bool // The queue ran empty; the next samples start a new stream
+
+	// Processing state, owned by the Process caller
+	history   []float64 // Aligned far-end samples, the last ones match the current chunk
+	weights   []float64
+	delay     int // Filter start in samples behind the far end, -1 until estimated
+	estimator *delayEstimator
+	farIdle   int     // Samples since the last non-zero far-end sample
+	erle      float64 // Smoothed ERLE in dB
+	converged bool
+	hold      int // Samples adaptation stays frozen
+	dtRun     int // Samples of continuous double talk
+	badRun    int // Samples of continuous divergence
+	resets    int
+
+	statsMutex sync.Mutex
+	stats      Stats
+}
+
+// NewCanceller creates an echo canceller
+func NewCanceller(cfg Config) *Canceller {
+	stepSize := cfg.StepSize
+	if stepSize <= 0 || stepSize > 1 {
+		stepSize = defaultStepSize
+	}
+
+	rate := float64(cfg.SampleRate)
+	taps := int(cfg.FilterLength.Seconds() * rate)
+	if taps < 1 {
+		taps = 1
+	}
+	maxDelay := int(cfg.MaxDelay.Seconds() * rate)
+
+	c := &Canceller{
+		sampleRate: cfg.SampleRate,
+		taps:       taps,
+		maxDelay:   maxDelay,
+		block:      int(blockDuration * rate),
+		stepSize:   stepSize,
+		eps:        float64(taps) * regularizeRMS * regularizeRMS,
+		starved:    true,
+		history:    make([]float64, maxDelay+taps),
+		weights:    make([]float64, taps),
+		delay:      -1,
+		estimator:  newDelayEstimator(maxDelay, taps/8),
+	}
+	c.farIdle = len(c.history)
+	return c
+}
+
+// PushFarEnd adds samples as they are handed to the loudspeaker
+func (c *Canceller) PushFarEnd(samples []int16) {
+	c.farMutex.Lock()
+	defer c.farMutex.Unlock()
+
+	c.farQueue = append(c.farQueue, samples...)
+	if len(samples) > c.farBlock {
+		c.farBlock = len(samples)
+	}
+
+	// Nothing consumes the queue while capture is stopped
+	if limit := int(farQueueLimit * float64(c.sampleRate)); len(c.farQueue) > limit {
+		c.farQueue = append(c.farQueue[:0], c.farQueue[len(c.farQueue)-limit:]...)
+	}
+}
+
+// Reset discards queued far-end audio and the alignment, e.g. when capture
+// restarts. The learned echo path is kept.
+func (c *Canceller) Reset() {
+	c.farMutex.Lock()
+	c.farQueue = c.farQueue[:0]
+	c.starved = true
+	c.farMutex.Unlock()
+
+	for i := range c.history {
+		c.history[i] = 0
+	}
+	c.farIdle = len(c.history)
+	c.hold = 0
+	c.dtRun = 0
+}
+
+// Stats returns the canceller's current state
+func (c *Canceller) Stats() Stats {
+	c.statsMutex.Lock()
+	defer c.statsMutex.Unlock()
+	return c.stats
+}
+
+// Process removes the echo from near-end samples and returns the result.
+// Without far-end audio the samples are returned unchanged.
+func (c *Canceller) Process(near []int16) []int16 {
+	n := len(near)
+	if n == 0 {
+		return near
+	}
+
+	far := c.alignFarEnd(n)
+	base := len(c.history)
+	for _, s := range far {
+		c.history = append(c.history, float64(s))
+		if s != 0 {
+			c.farIdle = 0
+		} else {
+			c.farIdle++
+		}
+	}
+	defer c.trimHistory()
+
+	// No far-end audio within reach of the filter
+	if c.farIdle >= n+c.maxDelay+c.taps {
+		c.updateStats(false, false)
+		return near
+	}
+
+	c.estimateDelay(near, base)
+	if c.delay < 0 {
+		c.updateStats(true, false)
+		return near
+	}
+
+	out := make([]int16, n)
+	doubleTalk := false
+	for start := 0; start < n; start += c.block {
+		end := start + c.block
+		if end > n {
+			end = n
+		}
+		if c.cancelBlock(near[start:end], out[start:end], base+start) {
+			doubleTalk = true
+		}
+	}
+	c.updateStats(true, doubleTalk)
+	return out
+}
+
+// alignFarEnd takes the far-end samples matching the next n near-end samples
+func (c *Canceller) alignFarEnd(n int) []int16 {
+	c.farMutex.Lock()
+	defer c.farMutex.Unlock()
+
+	far := make([]int16, n)
+	avail := len(c.farQueue)
+	if avail == 0 {
+		c.starved = true
+		return far
+	}
+
+	var take int
+	switch {
+	case c.starved:
+		// A new stream started within this chunk. Its samples go to the end
+		// of the chunk, and one playback block stays queued for jitter. The
+		// playback latency is at least one block, so the echo still trails.
+		take = avail - c.farBlock
+		if take < 0 {
+			take = 0
+		}
+		if take > n {
+			take = n
+		}
+		copy(far[n-take:], c.farQueue[:take])
+		c.starved = false
+	case avail < n:
+		// The stream ended (or stalled) within this chunk
+		take = avail
+		copy(far, c.farQueue[:take])
+		c.starved = true
+	default:
+		take = n
+		copy(far, c.farQueue[:take])
+	}
+	c.farQueue = append(c.farQueue[:0], c.farQueue[take:]...)
+	return far
+}
+
+// estimateDelay updates the bulk delay from the current chunk. A converged
+// filter proves the delay right, and near-end speech would mislead the
+// estimate, so it only runs until the filter converges.
+func (c *Canceller) estimateDelay(near []int16, base int) {
+	far := c.history[base-c.maxDelay:]
+	if c.converged || rms(far) < minEstimationRMS || rms(near) < minEstimationRMS {
+		return
+	}
+
+	lag, stable, ok := c.estimator.estimate(far, near)
+	if !ok {
+		return
+	}
+
+	// Place the direct path an eighth into the filter so early reflections
+	// and estimation error stay covered
+	start := lag - c.taps/8
+	if start < 0 {
+		start = 0
+	}
+	if c.delay >= 0 && abs(start-c.delay) <= c.taps/8 {
+		return
+	}
+	if c.delay >= 0 && !stable {
+		return // A single outlier does not discard the learned echo path
+	}
+
+	c.delay = start
+	c.resetFilter()
+}
+
+// cancelBlock filters one block of near-end samples into out and returns
+// whether double talk was detected
+func (c *Canceller) cancelBlock(near []int16, out []int16, base int) bool {
+	// First pass with the current filter decides whether to adapt
+	var nearPower, errPower, farPower float64
+	for i, d := range near {
+		pos := base + i - c.delay
+		e := float64(d) - c.filter(pos)
+		nearPower += float64(d) * float64(d)
+		errPower += e * e
+		farPower += c.history[pos] * c.history[pos]
+		out[i] = clip(e)
+	}
+
+	// Near-end speech shows as a drop of the block's ERLE. A residual
+	// louder than the microphone signal is a diverged filter instead.
+	farActive := farPower/float64(len(near)) >= regularizeRMS*regularizeRMS
+	blockERLE := 10 * math.Log10((nearPower+1)/(errPower+1))
+	if farActive && blockERLE <= divergedERLE {
+		c.badRun += len(near)
+		if c.badRun > int(divergedLimit*float64(c.sampleRate)) {
+			c.resetFilter()
+		}
+		copy(out, near)
+		return false
+	}
+	c.badRun = 0
+
+	doubleTalk := c.converged && farActive && blockERLE < c.erle-doubleTalkMargin
+	if doubleTalk {
+		c.hold = int(doubleTalkHold * float64(c.sampleRate))
+		c.dtRun += len(near)
+		if c.dtRun > int(doubleTalkLimit*float64(c.sampleRate)) {
+			// Persistent "double talk" is more likely a moved device
+			c.converged = false
+			c.dtRun = 0
+		}
+	} else {
+		c.dtRun = 0
+	}
+
+	if farActive && !doubleTalk && c.hold == 0 {
+		c.erle += (blockERLE - c.erle) * erleSmoothing
+		c.converged = c.erle >= convergedERLE
+	}
+
+	// The residual is never louder than the microphone signal
+	if errPower > nearPower {
+		copy(out, near)
+	}
+
+	if c.hold > 0 {
+		c.hold -= len(near)
+		if c.hold < 0 {
+			c.hold = 0
+		}
+		return doubleTalk
+	}
+	if !farActive {
+		return false
+	}
+
+	// Second pass adapts the filter sample by sample
+	pos := base - c.delay
+	energy := 0.0
+	for k := 0; k < c.taps; k++ {
+		x := c.history[pos-k]
+		energy += x * x
+	}
+	for i, d := range near {
+		if i > 0 {
+			pos++
+			in, gone := c.history[pos], c.history[pos-c.taps]
+			energy = math.Max(0, energy+in*in-gone*gone)
+		}
+		e := float64(d) - c.filter(pos)
+		g := c.stepSize * e / (energy + c.eps)
+		x := c.history[pos-c.taps+1 : pos+1]
+		for k := range c.weights {
+			c.weights[k] += g * x[len(x)-1-k]
+		}
+		if errPower <= nearPower {
+			out[i] = clip(e)
+		}
+	}
+	return false
+}
+
+// filter returns the echo estimate for the far-end sample at pos
+func (c *Canceller) filter(pos int) float64 {
+	x := c.history[pos-c.taps+1 : pos+1]
+	var y float64
+	for k, w := range c.weights {
+		y += w * x[len(x)-1-k]
+	}
+	return y
+}
+
+// resetFilter forgets the learned echo path
+func (c *Canceller) resetFilter() {
+	for i := range c.weights {
+		c.weights[i] = 0
+	}
+	c.erle = 0
+	c.converged = false
+	c.hold = 0
+	c.dtRun = 0
+	c.badRun = 0
+	c.resets++
+}
+
+// trimHistory keeps the far-end samples the next chunk can reach
+func (c *Canceller) trimHistory() {
+	keep := c.maxDelay + c.taps
+	if len(c.history) > keep {
+		c.history = append(c.history[:0], c.history[len(c.history)-keep:]...)
+	}
+}
+
+// updateStats publishes the state after a chunk
+func (c *Canceller) updateStats(active, doubleTalk bool) {
+	c.statsMutex.Lock()
+	defer c.statsMutex.Unlock()
+	c.stats = Stats{
+		Active:      active,
+		DelayLocked: c.delay >= 0,
+		ERLE:        math.Round(c.erle*10) / 10,
+		Converged:   c.converged,
+		DoubleTalk:  doubleTalk,
+		Resets:      c.resets,
+	}
+	if c.delay >= 0 {
+		c.stats.DelayMs = float64(c.delay+c.taps/8) * 1000 / float64(c.sampleRate)
+	}
+}
+
+// rms returns the RMS level of the samples
+func rms[T int16 | float64](samples []T) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+// clip converts to int16 with saturation
+func clip(v float64) int16 {
+	switch {
+	case v > math.MaxInt16:
+		return math.MaxInt16
+	case v < math.MinInt16:
+		return math.MinInt16
+	default:
+		return int16(math.Round(v))
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,136 @@
+package aec
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+const (
+	testRate      = 16000
+	testChunk     = 3200 // 200ms, as delivered by the recorder
+	playbackBlock = 256  // Samples per simulated playback callback
+)
+
+func TestEchoReduction(t *testing.T) {
+	tests := []struct {
+		name   string
+		delay  time.Duration
+		minERL float64 // Required echo reduction in dB
+	}{
+		{"short delay", 20 * time.Millisecond, 15},
+		{"room delay", 120 * time.Millisecond, 15},
+		{"long delay", 350 * time.Millisecond, 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			far, near := synthesize(8*time.Second, tt.delay)
+			c := newTestCanceller()
+			out := run(c, far, near)
+
+			// Measure after convergence
+			half := len(out) / 2
+			reduction := energyDB(near[half:len(out)]) - energyDB(out[half:])
+			if reduction < tt.minERL {
+				t.Errorf("echo reduction = %.1f dB, want >= %.1f dB", reduction, tt.minERL)
+			}
+
+			stats := c.Stats()
+			if !stats.DelayLocked {
+				t.Fatal("delay not locked")
+			}
+			// A lock within an eighth of the filter (8ms) is kept
+			if diff := math.Abs(stats.DelayMs - float64(tt.delay.Milliseconds())); diff > 10 {
+				t.Errorf("estimated delay = %.1f ms, want %v", stats.DelayMs, tt.delay)
+			}
+		})
+	}
+}
+
+func TestProcessWithoutFarEnd(t *testing.T) {
+	_, near := synthesize(time.Second, 0)
+	c := newTestCanceller()
+
+	out := c.Process(near[:testChunk])
+	for i := range out {
+		if out[i] != near[i] {
+			t.Fatalf("sample %d changed without far-end audio: %d -> %d", i, near[i], out[i])
+		}
+	}
+}
+
+func newTestCanceller() *Canceller {
+	return NewCanceller(Config{
+		SampleRate:   testRate,
+		FilterLength: 64 * time.Millisecond,
+		MaxDelay:     500 * time.Millisecond,
+	})
+}
+
+// run feeds the far end in playback-callback blocks ahead of each
+// microphone chunk, as the player and recorder do
+func run(c *Canceller, far, near []int16) []int16 {
+	out := make([]int16, 0, len(near))
+	pushed := 0
+	for pos := 0; pos+testChunk <= len(near); pos += testChunk {
+		for ; pushed < pos+testChunk && pushed < len(far); pushed += playbackBlock {
+			c.PushFarEnd(far[pushed:min(pushed+playbackBlock, len(far))])
+		}
+		out = append(out, c.Process(near[pos:pos+testChunk])...)
+	}
+	return out
+}
+
+// synthesize creates speech-like playback and the microphone signal holding
+// its echo after a room echo path and the given delay
+func synthesize(duration, delay time.Duration) (far, near []int16) {
+	rng := rand.New(rand.NewSource(1))
+	n := int(duration.Seconds() * testRate)
+
+	// Pulse train with a wandering pitch through a resonance, in syllables
+	far = make([]int16, n)
+	var phase, r1, r2 float64
+	for i := range far {
+		t := float64(i) / testRate
+		phase += 140 * (1 + 0.15*math.Sin(2*math.Pi*0.7*t)) / testRate
+		excitation := rng.NormFloat64() * 0.1
+		if phase >= 1 {
+			phase--
+			excitation++
+		}
+		y := excitation + 1.8*math.Cos(2*math.Pi*700/testRate)*r1*0.95 - 0.9025*r2
+		r2, r1 = r1, y
+		envelope := math.Max(0, math.Sin(2*math.Pi*2.3*t)+0.6*math.Sin(2*math.Pi*0.9*t))
+		far[i] = clip(y * 600 * envelope)
+	}
+
+	// Direct sound followed by decaying reflections
+	path := make([]float64, testRate*40/1000)
+	path[0] = 0.6
+	for i := 1; i < len(path); i++ {
+		path[i] = rng.NormFloat64() * 0.08 * math.Exp(-float64(i)/float64(len(path)/4))
+	}
+
+	offset := int(delay.Seconds() * testRate)
+	near = make([]int16, n)
+	for i := range near {
+		var echo float64
+		for k, h := range path {
+			if j := i - offset - k; j >= 0 {
+				echo += h * float64(far[j])
+			}
+		}
+		near[i] = clip(echo + rng.NormFloat64()*20)
+	}
+	return far, near
+}
+
+func energyDB(samples []int16) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return 10 * math.Log10(sum+1)
+}
@@ -0,0 +1,76 @@
+package aec
+
+import (
+	"math"
+	"math/cmplx"
+
+	"websocket_client_chat/pkg/utils"
+)
+
+// minPeakRatio is how far the correlation peak must stand above the
+// average correlation for an estimate to count
+const minPeakRatio = 8.0
+
+// delayEstimator finds the playback-to-capture delay with the generalized
+// cross-correlation with phase transform (GCC-PHAT), which keeps a sharp
+// peak for speech whose plain correlation is smeared by its pitch
+type delayEstimator struct {
+	maxDelay  int
+	tolerance int // Estimates this close count as the same delay
+	last      int // Previous confident estimate, -1 if none
+}
+
+// newDelayEstimator creates an estimator for delays up to maxDelay samples
+func newDelayEstimator(maxDelay, tolerance int) *delayEstimator {
+	return &delayEstimator{maxDelay: maxDelay, tolerance: tolerance, last: -1}
+}
+
+// estimate returns the delay of near behind far, where far holds maxDelay
+// samples before near followed by the samples aligned with it. stable
+// reports whether the previous confident estimate agrees.
+func (de *delayEstimator) estimate(far []float64, near []int16) (lag int, stable bool, ok bool) {
+	size := 1
+	for size < len(far) {
+		size <<= 1
+	}
+
+	x := make([]complex128, size)
+	for i, v := range far {
+		x[i] = complex(v, 0)
+	}
+	y := make([]complex128, size)
+	for i, v := range near {
+		y[i] = complex(float64(v), 0)
+	}
+	utils.FFT(x)
+	utils.FFT(y)
+
+	// Cross spectrum, whitened, then inverse transformed via conjugation
+	for i := range x {
+		c := cmplx.Conj(y[i]) * x[i]
+		if m := cmplx.Abs(c); m > 1e-9 {
+			c /= complex(m, 0)
+		}
+		x[i] = cmplx.Conj(c)
+	}
+	utils.FFT(x)
+
+	// Correlation at shift m matches near with far[m:], a delay of maxDelay-m
+	best, peak, sum := 0, 0.0, 0.0
+	for m := 0; m <= de.maxDelay && m < size; m++ {
+		v := real(x[m])
+		sum += math.Abs(v)
+		if v > peak {
+			best, peak = m, v
+		}
+	}
+	mean := sum / float64(de.maxDelay+1)
+	if mean == 0 || peak/mean < minPeakRatio {
+		return 0, false, false
+	}
+
+	lag = de.maxDelay - best
+	stable = de.last >= 0 && abs(lag-de.last) <= de.tolerance
+	de.last = lag
+	return lag, stable, true
+}
@@ -0,0 +1,110 @@
+// Package codec provides the audio codecs used on the WebSocket link.
+//
+// PCM (WAV-wrapped 16-bit PCM) is the default. Opus requires libopus and is
+// only available when built with the "opus" build tag.
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"websocket_client_chat/pkg/utils"
+)
+
+// Codec names
+const (
+	PCM  = "pcm"
+	Opus = "opus"
+)
+
+// Config is the codec configuration
+type Config struct {
+	Codec         string        // PCM or Opus
+	SampleRate    int           // Sample rate in Hz
+	Channels      int           // Number of channels
+	BitDepth      int           // Bytes per sample (PCM only)
+	Bitrate       int           // Target bitrate in bits/s (Opus only)
+	FrameDuration time.Duration // Frame duration (Opus only, e.g. 20ms)
+}
+
+// Encoder turns captured samples into a message payload
+type Encoder interface {
+	Encode(samples []int16) ([]byte, error)
+	Close() error
+}
+
+// Decoder turns a received payload into 16-bit little-endian PCM
+type Decoder interface {
+	Decode(data []byte) ([]byte, error)
+	Close() error
+}
+
+// NewEncoder creates an encoder for cfg.Codec
+func NewEncoder(cfg Config) (Encoder, error) {
+	switch cfg.Codec {
+	case "", PCM:
+		return &pcmEncoder{config: cfg}, nil
+	case Opus:
+		return newOpusEncoder(cfg)
+	default:
+		return nil, fmt.Errorf("unknown audio codec %q", cfg.Codec)
+	}
+}
+
+// NewDecoder creates a decoder for cfg.Codec
+func NewDecoder(cfg Config) (Decoder, error) {
+	switch cfg.Codec {
+	case "", PCM:
+		return pcmDecoder{}, nil
+	case Opus:
+		return newOpusDecoder(cfg)
+	default:
+		return nil, fmt.Errorf("unknown audio codec %q", cfg.Codec)
+	}
+}
+
+// pcmEncoder wraps samples in a WAV header (the original wire format)
+type pcmEncoder struct {
+	config Config
+}
+
+func (e *pcmEncoder) Encode(samples []int16) ([]byte, error) {
+	return utils.ConvertSamplesToWAV(samples, e.config.SampleRate, e.config.Channels, e.config.BitDepth), nil
+}
+
+func (e *pcmEncoder) Close() error { return nil }
+
+// pcmDecoder passes raw PCM through unchanged
+type pcmDecoder struct{}
+
+func (pcmDecoder) Decode(data []byte) ([]byte, error) { return data, nil }
+
+func (pcmDecoder) Close() error { return nil }
+
+// Opus payloads carry several packets per message, each prefixed with its
+// length as a big-endian uint16.
+
+// appendPacket appends a length-prefixed packet to buf
+func appendPacket(buf []byte, packet []byte) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(packet)))
+	return append(buf, packet...)
+}
+
+// splitPackets splits a payload into its length-prefixed packets
+func splitPackets(data []byte) ([][]byte, error) {
+	var packets [][]byte
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, fmt.Errorf("truncated packet length")
+		}
+		n := int(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		if n > len(data) {
+			return nil, fmt.Errorf("truncated packet (%d > %d bytes)", n, len(data))
+		}
+		packets = append(packets, data[:n])
+		data = data[n:]
+	}
+	return packets, nil
+}
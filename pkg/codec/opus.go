@@ -0,0 +1,164 @@
+//go:build opus
+
+package codec
+
+/*
+#cgo pkg-config: opus
+#include <opus.h>
+
+// opus_encoder_ctl is variadic and cannot be called from Go directly
+static int set_bitrate(OpusEncoder *enc, opus_int32 bitrate) {
+	return opus_encoder_ctl(enc, OPUS_SET_BITRATE(bitrate));
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// maxPacketSize is the largest Opus packet we accept from the encoder
+const maxPacketSize = 4000
+
+// opusEncoder encodes samples into length-prefixed Opus packets
+type opusEncoder struct {
+	enc       *C.OpusEncoder
+	frameSize int // Samples per channel per frame
+	channels  int
+	packet    []byte
+	mutex     sync.Mutex
+}
+
+func newOpusEncoder(cfg Config) (Encoder, error) {
+	frameDuration := cfg.FrameDuration
+	if frameDuration <= 0 {
+		frameDuration = 20 * time.Millisecond
+	}
+
+	var errCode C.int
+	enc := C.opus_encoder_create(C.opus_int32(cfg.SampleRate), C.int(cfg.Channels), C.OPUS_APPLICATION_VOIP, &errCode)
+	if errCode != C.OPUS_OK {
+		return nil, fmt.Errorf("opus encoder init failed: %s", C.GoString(C.opus_strerror(errCode)))
+	}
+
+	if cfg.Bitrate > 0 {
+		if rc := C.set_bitrate(enc, C.opus_int32(cfg.Bitrate)); rc != C.OPUS_OK {
+			C.opus_encoder_destroy(enc)
+			return nil, fmt.Errorf("opus set bitrate failed: %s", C.GoString(C.opus_strerror(rc)))
+		}
+	}
+
+	return &opusEncoder{
+		enc:       enc,
+		frameSize: int(int64(cfg.SampleRate) * int64(frameDuration) / int64(time.Second)),
+		channels:  cfg.Channels,
+		packet:    make([]byte, maxPacketSize),
+	}, nil
+}
+
+// Encode splits samples into frames and encodes each one. A trailing
+// partial frame is padded with silence.
+func (e *opusEncoder) Encode(samples []int16) ([]byte, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.enc == nil {
+		return nil, fmt.Errorf("opus encoder closed")
+	}
+
+	step := e.frameSize * e.channels
+	var out []byte
+	frame := make([]int16, step)
+	for pos := 0; pos < len(samples); pos += step {
+		n := copy(frame, samples[pos:])
+		for i := n; i < step; i++ {
+			frame[i] = 0
+		}
+
+		size := C.opus_encode(e.enc,
+			(*C.opus_int16)(unsafe.Pointer(&frame[0])), C.int(e.frameSize),
+			(*C.uchar)(unsafe.Pointer(&e.packet[0])), C.opus_int32(len(e.packet)))
+		if size < 0 {
+			return nil, fmt.Errorf("opus encode failed: %s", C.GoString(C.opus_strerror(C.int(size))))
+		}
+		out = appendPacket(out, e.packet[:size])
+	}
+	return out, nil
+}
+
+func (e *opusEncoder) Close() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.enc != nil {
+		C.opus_encoder_destroy(e.enc)
+		e.enc = nil
+	}
+	return nil
+}
+
+// opusDecoder decodes length-prefixed Opus packets to PCM
+type opusDecoder struct {
+	dec      *C.OpusDecoder
+	channels int
+	pcm      []int16 // Scratch buffer sized for the longest Opus frame (120ms)
+	mutex    sync.Mutex
+}
+
+func newOpusDecoder(cfg Config) (Decoder, error) {
+	var errCode C.int
+	dec := C.opus_decoder_create(C.opus_int32(cfg.SampleRate), C.int(cfg.Channels), &errCode)
+	if errCode != C.OPUS_OK {
+		return nil, fmt.Errorf("opus decoder init failed: %s", C.GoString(C.opus_strerror(errCode)))
+	}
+
+	return &opusDecoder{
+		dec:      dec,
+		channels: cfg.Channels,
+		pcm:      make([]int16, cfg.SampleRate*120/1000*cfg.Channels),
+	}, nil
+}
+
+func (d *opusDecoder) Decode(data []byte) ([]byte, error) {
+	packets, err := splitPackets(data)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.dec == nil {
+		return nil, fmt.Errorf("opus decoder closed")
+	}
+
+	var out []byte
+	maxFrame := len(d.pcm) / d.channels
+	for _, packet := range packets {
+		if len(packet) == 0 {
+			continue
+		}
+		n := C.opus_decode(d.dec,
+			(*C.uchar)(unsafe.Pointer(&packet[0])), C.opus_int32(len(packet)),
+			(*C.opus_int16)(unsafe.Pointer(&d.pcm[0])), C.int(maxFrame), 0)
+		if n < 0 {
+			return nil, fmt.Errorf("opus decode failed: %s", C.GoString(C.opus_strerror(n)))
+		}
+		for _, s := range d.pcm[:int(n)*d.channels] {
+			out = append(out, byte(s), byte(uint16(s)>>8))
+		}
+	}
+	return out, nil
+}
+
+func (d *opusDecoder) Close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.dec != nil {
+		C.opus_decoder_destroy(d.dec)
+		d.dec = nil
+	}
+	return nil
+}
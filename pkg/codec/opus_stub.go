@@ -0,0 +1,16 @@
+//go:build !opus
+
+package codec
+
+import "fmt"
+
+// errOpusUnavailable is returned when the binary was built without libopus
+var errOpusUnavailable = fmt.Errorf("opus support not compiled in (build with -tags opus)")
+
+func newOpusEncoder(_ Config) (Encoder, error) {
+	return nil, errOpusUnavailable
+}
+
+func newOpusDecoder(_ Config) (Decoder, error) {
+	return nil, errOpusUnavailable
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+	"os"
 	"time"
 )
 
@@ -77,6 +78,61 @@ func ConvertSamplesToWAV(audioSamples []int16, sampleRate int, channels int, bit
 	return wavData
 }
 
+// ReadWAV reads a 16-bit PCM WAV file and returns its samples (multiple
+// channels are mixed down to mono) and sample rate
+func ReadWAV(path string) ([]int16, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read WAV file: %w", err)
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, fmt.Errorf("%s is not a WAV file", path)
+	}
+
+	channels, sampleRate := 0, 0
+	pos := 12
+	for pos+8 <= len(data) {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		pos += 8
+		end := pos + size
+		if end > len(data) {
+			end = len(data)
+		}
+
+		switch id {
+		case "fmt ":
+			if end-pos < 16 {
+				return nil, 0, fmt.Errorf("invalid fmt chunk in %s", path)
+			}
+			format := binary.LittleEndian.Uint16(data[pos:])
+			bits := binary.LittleEndian.Uint16(data[pos+14:])
+			if format != 1 || bits != 16 {
+				return nil, 0, fmt.Errorf("%s is not 16-bit PCM (format %d, %d bits)", path, format, bits)
+			}
+			channels = int(binary.LittleEndian.Uint16(data[pos+2:]))
+			sampleRate = int(binary.LittleEndian.Uint32(data[pos+4:]))
+
+		case "data":
+			if channels == 0 {
+				return nil, 0, fmt.Errorf("missing fmt chunk before data in %s", path)
+			}
+			frames := (end - pos) / (2 * channels)
+			samples := make([]int16, frames)
+			for i := range samples {
+				var sum int
+				for ch := 0; ch < channels; ch++ {
+					sum += int(int16(binary.LittleEndian.Uint16(data[pos+(i*channels+ch)*2:])))
+				}
+				samples[i] = int16(sum / channels)
+			}
+			return samples, sampleRate, nil
+		}
+		pos += size + size%2
+	}
+	return nil, 0, fmt.Errorf("no data chunk in WAV file %s", path)
+}
+
 // ResampleAudio resamples audio from one sample rate to another using linear interpolation
 // This is a simple but effective resampling method suitable for speech audio
 func ResampleAudio(input []int16, fromRate, toRate int) []int16 {
@@ -0,0 +1,52 @@
+package utils
+
+import "sort"
+
+// noiseFloorPercentile selects the quiet end of the recent chunk levels, so
+// short sounds (a door, a word) do not raise the estimate
+const noiseFloorPercentile = 0.2
+
+// NoiseFloor estimates the ambient noise level from the RMS of recent audio
+// chunks. It is not safe for concurrent use.
+type NoiseFloor struct {
+	levels   []float64 // Ring of chunk RMS values
+	pos      int
+	count    int
+	minCount int // Chunks needed before the estimate is used
+}
+
+// NewNoiseFloor creates an estimator over the last windowChunks chunks. The
+// estimate is ready once minChunks chunks were seen.
+func NewNoiseFloor(windowChunks, minChunks int) *NoiseFloor {
+	if windowChunks < 1 {
+		windowChunks = 1
+	}
+	if minChunks > windowChunks {
+		minChunks = windowChunks
+	}
+	return &NoiseFloor{levels: make([]float64, windowChunks), minCount: minChunks}
+}
+
+// Update adds an audio chunk
+func (n *NoiseFloor) Update(samples []int16) {
+	if len(samples) == 0 {
+		return
+	}
+	n.levels[n.pos] = CalculateRMS(samples)
+	n.pos = (n.pos + 1) % len(n.levels)
+	if n.count < len(n.levels) {
+		n.count++
+	}
+}
+
+// Estimate returns the noise floor RMS and whether enough audio was seen
+func (n *NoiseFloor) Estimate() (float64, bool) {
+	if n.count == 0 {
+		return 0, false
+	}
+
+	sorted := make([]float64, n.count)
+	copy(sorted, n.levels[:n.count])
+	sort.Float64s(sorted)
+	return sorted[int(float64(n.count-1)*noiseFloorPercentile)], n.count >= n.minCount
+}
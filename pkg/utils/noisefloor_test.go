@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// testChunk is one 200ms recorder chunk at testSampleRate
+const testChunk = testSampleRate / 5
+
+func TestNoiseFloorConvergesOnSteadyNoise(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  float64 // Noise level filling the window first, 0 for none
+		steady  float64
+		chunks  int
+		wantRMS float64
+	}{
+		{"from start", 0, 100, 50, 100},
+		{"quieter room", 300, 40, 50, 40},
+		{"louder room", 40, 300, 50, 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			nf := NewNoiseFloor(50, 10)
+			if tt.before > 0 {
+				for i := 0; i < 50; i++ {
+					nf.Update(noise(rng, testChunk, tt.before))
+				}
+			}
+			for i := 0; i < tt.chunks; i++ {
+				nf.Update(noise(rng, testChunk, tt.steady))
+			}
+
+			floor, ready := nf.Estimate()
+			if !ready {
+				t.Fatal("estimate not ready")
+			}
+			if math.Abs(floor-tt.wantRMS) > tt.wantRMS*0.05 {
+				t.Errorf("floor = %.1f, want %.1f", floor, tt.wantRMS)
+			}
+		})
+	}
+}
+
+func TestNoiseFloorHeldDuringSpeech(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	nf := NewNoiseFloor(50, 10)
+	for i := 0; i < 50; i++ {
+		nf.Update(noise(rng, testChunk, 100))
+	}
+	before, _ := nf.Estimate()
+
+	// Speech filling most of the window leaves the quiet end in place
+	for i := 0; i < 35; i++ {
+		nf.Update(tone(testChunk, 3000))
+		if floor, _ := nf.Estimate(); math.Abs(floor-before) > before*0.05 {
+			t.Fatalf("floor moved from %.1f to %.1f after %d speech chunks", before, floor, i+1)
+		}
+	}
+}
+
+func TestNoiseFloorNotReadyBeforeMeasured(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	nf := NewNoiseFloor(50, 10)
+
+	if _, ready := nf.Estimate(); ready {
+		t.Fatal("empty estimator is ready")
+	}
+	nf.Update(nil)
+	for i := 1; i <= 10; i++ {
+		nf.Update(noise(rng, testChunk, 100))
+		if _, ready := nf.Estimate(); ready != (i >= 10) {
+			t.Fatalf("after %d chunks ready = %v, want %v", i, ready, i >= 10)
+		}
+	}
+}
+
+func TestVADMinLevelFallsBackBeforeFloorMeasured(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	nf := NewNoiseFloor(50, 10)
+	for i := 0; i < 5; i++ {
+		nf.Update(noise(rng, testChunk, 100))
+	}
+
+	// The app raises the VAD minimum to the speech threshold only once the
+	// floor is measured, and passes 0 otherwise
+	v := NewVAD(testSampleRate, 1)
+	v.SetMinLevel(1000)
+	minLevel := 0.0
+	if floor, ready := nf.Estimate(); ready {
+		minLevel = floor * 3
+	}
+	v.SetMinLevel(minLevel)
+	if v.minDB != vadMinEnergyDB {
+		t.Fatalf("minimum = %.1f dB before the floor is measured, want the default %.1f dB", v.minDB, vadMinEnergyDB)
+	}
+
+	// A soft voice above the default minimum is speech again
+	var samples []int16
+	samples = append(samples, silence(rng, 25*testFrame)...)
+	samples = append(samples, tone(25*testFrame, 60)...)
+	runVAD(v, samples)
+	if !v.HeardSpeech() {
+		t.Error("soft voice not detected with the default minimum")
+	}
+
+	v.Reset()
+	v.SetMinLevel(1000)
+	runVAD(v, tone(25*testFrame, 60))
+	if v.HeardSpeech() {
+		t.Error("soft voice detected below a raised minimum")
+	}
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// VADEvent is a change of the voice activity state
+type VADEvent int
+
+const (
+	VADSpeechStart VADEvent = iota + 1 // Speech onset, after the onset frames
+	VADSpeechEnd                       // Speech offset, after the hangover
+)
+
+// String returns a human-readable name for the event
+func (e VADEvent) String() string {
+	switch e {
+	case VADSpeechStart:
+		return "speechStart"
+	case VADSpeechEnd:
+		return "speechEnd"
+	default:
+		return "none"
+	}
+}
+
+// VAD frame analysis parameters
+const (
+	vadFrameDuration = 0.02  // Seconds per analysis frame
+	vadMinEnergyDB   = 30.0  // Default minimum speech level (RMS ~30)
+	vadBandLowHz     = 100.0 // Speech band, above mains hum
+	vadBandHighHz    = 4000.0
+	vadFloorRise     = 0.02  // Noise floor adaptation per non-speech frame (~1 s)
+	vadFloorCreep    = 0.001 // Adaptation during speech, absorbs stationary noise (~20 s)
+)
+
+// vadProfile holds the thresholds of one aggressiveness level
+type vadProfile struct {
+	marginDB    float64 // Required level above the noise floor
+	maxFlatness float64 // Spectral flatness above this is noise-like (fans, hiss)
+	minBandPart float64 // Required share of energy in the speech band
+	onset       int     // Consecutive speech frames to start speech
+	hangover    int     // Non-speech frames before speech ends
+}
+
+// vadProfiles are indexed by aggressiveness; higher rejects more non-speech
+var vadProfiles = [...]vadProfile{
+	{marginDB: 6, maxFlatness: 0.5, minBandPart: 0.5, onset: 2, hangover: 25},
+	{marginDB: 8, maxFlatness: 0.45, minBandPart: 0.6, onset: 3, hangover: 20},
+	{marginDB: 10, maxFlatness: 0.4, minBandPart: 0.7, onset: 4, hangover: 15},
+	{marginDB: 12, maxFlatness: 0.35, minBandPart: 0.8, onset: 5, hangover: 10},
+}
+
+// VAD is a frame-level voice activity detector. Each 20 ms frame is
+// classified by its level above an adaptive noise floor, its spectral
+// flatness and its share of energy in the speech band; onset frames and a
+// hangover smooth the decision into speech segments. It is not safe for
+// concurrent use.
+type VAD struct {
+	profile    vadProfile
+	frameSize  int
+	fftSize    int
+	sampleRate int
+
+	pending    []int16
+	minDB      float64 // Frames below this level are never speech
+	floorDB    float64 // Noise floor estimate, 0 until the first frame
+	inSpeech   bool
+	run        int // Consecutive frames contradicting the current state
+	silent     int // Frames since the last speech frame
+	heardVoice bool
+}
+
+// NewVAD creates a detector. aggressiveness ranges from 0 (keeps the most
+// audio as speech) to 3 (most strict).
+func NewVAD(sampleRate int, aggressiveness int) *VAD {
+	if aggressiveness < 0 {
+		aggressiveness = 0
+	}
+	if aggressiveness >= len(vadProfiles) {
+		aggressiveness = len(vadProfiles) - 1
+	}
+
+	frameSize := int(vadFrameDuration * float64(sampleRate))
+	fftSize := 1
+	for fftSize < frameSize {
+		fftSize <<= 1
+	}
+	return &VAD{
+		profile:    vadProfiles[aggressiveness],
+		frameSize:  frameSize,
+		fftSize:    fftSize,
+		sampleRate: sampleRate,
+		minDB:      vadMinEnergyDB,
+	}
+}
+
+// SetMinLevel raises the absolute level (RMS) below which frames are never
+// speech, e.g. to a threshold derived from the ambient noise
+func (v *VAD) SetMinLevel(rms float64) {
+	v.minDB = math.Max(vadMinEnergyDB, 10*math.Log10(rms*rms+1))
+}
+
+// Process classifies the samples and returns the speech onsets and offsets
+// that occurred within them, in order
+func (v *VAD) Process(samples []int16) []VADEvent {
+	v.pending = append(v.pending, samples...)
+
+	var events []VADEvent
+	for len(v.pending) >= v.frameSize {
+		if event := v.update(v.isSpeechFrame(v.pending[:v.frameSize])); event != 0 {
+			events = append(events, event)
+		}
+		v.pending = v.pending[v.frameSize:]
+	}
+	v.pending = append(v.pending[:0:0], v.pending...)
+	return events
+}
+
+// InSpeech reports whether speech is ongoing (including the hangover)
+func (v *VAD) InSpeech() bool {
+	return v.inSpeech
+}
+
+// HeardSpeech reports whether any speech was detected since the last reset
+func (v *VAD) HeardSpeech() bool {
+	return v.heardVoice
+}
+
+// SilenceSamples returns the number of samples since the last speech frame,
+// or since the last reset if there was none
+func (v *VAD) SilenceSamples() int {
+	return v.silent * v.frameSize
+}
+
+// Reset starts a new utterance. The noise floor estimate is kept.
+func (v *VAD) Reset() {
+	v.pending = v.pending[:0]
+	v.inSpeech = false
+	v.run = 0
+	v.silent = 0
+	v.heardVoice = false
+}
+
+// update advances the smoothed state by one frame decision
+func (v *VAD) update(speech bool) VADEvent {
+	if speech {
+		v.silent = 0
+	} else {
+		v.silent++
+	}
+
+	if speech == v.inSpeech {
+		v.run = 0
+		return 0
+	}
+	v.run++
+
+	if !v.inSpeech && v.run >= v.profile.onset {
+		v.inSpeech = true
+		v.heardVoice = true
+		v.run = 0
+		return VADSpeechStart
+	}
+	if v.inSpeech && v.run >= v.profile.hangover {
+		v.inSpeech = false
+		v.run = 0
+		return VADSpeechEnd
+	}
+	return 0
+}
+
+// isSpeechFrame classifies one frame and adapts the noise floor
+func (v *VAD) isSpeechFrame(frame []int16) bool {
+	var energy float64
+	buf := make([]complex128, v.fftSize)
+	for i, s := range frame {
+		x := float64(s)
+		energy += x * x
+		buf[i] = complex(x, 0)
+	}
+	levelDB := 10 * math.Log10(energy/float64(len(frame))+1)
+
+	if v.floorDB == 0 {
+		v.floorDB = levelDB
+	}
+
+	speech := levelDB >= v.minDB && levelDB-v.floorDB >= v.profile.marginDB
+	if speech {
+		flatness, bandPart := v.spectralFeatures(buf)
+		speech = flatness <= v.profile.maxFlatness && bandPart >= v.profile.minBandPart
+	}
+
+	// The floor follows drops immediately and rises slowly, even during
+	// speech, so a stationary noise source is eventually not speech
+	switch {
+	case levelDB < v.floorDB:
+		v.floorDB = levelDB
+	case speech:
+		v.floorDB += (levelDB - v.floorDB) * vadFloorCreep
+	default:
+		v.floorDB += (levelDB - v.floorDB) * vadFloorRise
+	}
+	return speech
+}
+
+// spectralFeatures returns the spectral flatness within the speech band
+// (geometric over arithmetic mean of the power, high for noise, low for
+// harmonic sounds) and the share of the frame's energy that falls into the
+// speech band
+func (v *VAD) spectralFeatures(buf []complex128) (float64, float64) {
+	FFT(buf)
+
+	binHz := float64(v.sampleRate) / float64(v.fftSize)
+	var total, band, logSum float64
+	count := 0
+	for i := 1; i <= v.fftSize/2; i++ {
+		m := cmplx.Abs(buf[i])
+		p := m*m + 1e-9
+		total += p
+
+		hz := float64(i) * binHz
+		if hz >= vadBandLowHz && hz <= vadBandHighHz {
+			band += p
+			logSum += math.Log(p)
+			count++
+		}
+	}
+	if count == 0 || total == 0 {
+		return 1, 0
+	}
+
+	flatness := math.Exp(logSum/float64(count)) / (band / float64(count))
+	return flatness, band / total
+}
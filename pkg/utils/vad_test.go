@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+const testSampleRate = 16000
+
+// testFrame is one 20ms VAD frame at testSampleRate
+const testFrame = testSampleRate / 50
+
+// tone returns n samples of a voiced sound: a 200 Hz fundamental with
+// decaying harmonics up to 3 kHz
+func tone(n int, rms float64) []int16 {
+	out := make([]float64, n)
+	for h := 1; h <= 15; h++ {
+		for i := range out {
+			out[i] += math.Sin(2*math.Pi*200*float64(h)*float64(i)/testSampleRate) / float64(h)
+		}
+	}
+	return scale(out, rms)
+}
+
+// noise returns n samples of white noise
+func noise(rng *rand.Rand, n int, rms float64) []int16 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = rng.NormFloat64()
+	}
+	return scale(out, rms)
+}
+
+// silence returns n samples of a quiet room. A microphone never delivers
+// digital zeros, so this is low-level noise.
+func silence(rng *rand.Rand, n int) []int16 {
+	return noise(rng, n, 5)
+}
+
+func scale(signal []float64, rms float64) []int16 {
+	var sum float64
+	for _, x := range signal {
+		sum += x * x
+	}
+	gain := rms / math.Sqrt(sum/float64(len(signal)))
+	out := make([]int16, len(signal))
+	for i, x := range signal {
+		out[i] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, x*gain)))
+	}
+	return out
+}
+
+// vadEventAt is an event and the frame index it was reported in
+type vadEventAt struct {
+	frame int
+	event VADEvent
+}
+
+// runVAD feeds the samples frame by frame and records the events
+func runVAD(v *VAD, samples []int16) []vadEventAt {
+	var events []vadEventAt
+	for i := 0; i+testFrame <= len(samples); i += testFrame {
+		for _, e := range v.Process(samples[i : i+testFrame]) {
+			events = append(events, vadEventAt{frame: i / testFrame, event: e})
+		}
+	}
+	return events
+}
+
+func TestVADClassification(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	lead := silence(rng, 25*testFrame)
+
+	tests := []struct {
+		name       string
+		signal     []int16
+		wantSpeech bool
+	}{
+		{"silence", silence(rng, 50*testFrame), false},
+		{"voiced tone", tone(50*testFrame, 3000), true},
+		{"quiet voiced tone", tone(50*testFrame, 20), false},
+		{"white noise", noise(rng, 50*testFrame, 3000), false},
+	}
+
+	for _, tt := range tests {
+		for aggressiveness := range vadProfiles {
+			v := NewVAD(testSampleRate, aggressiveness)
+			runVAD(v, append(append([]int16{}, lead...), tt.signal...))
+			if got := v.HeardSpeech(); got != tt.wantSpeech {
+				t.Errorf("%s (aggressiveness %d): HeardSpeech() = %v, want %v",
+					tt.name, aggressiveness, got, tt.wantSpeech)
+			}
+		}
+	}
+}
+
+func TestVADOnsetAndHangover(t *testing.T) {
+	const leadFrames, speechFrames, tailFrames = 25, 40, 40
+
+	for aggressiveness, profile := range vadProfiles {
+		rng := rand.New(rand.NewSource(2))
+		var samples []int16
+		samples = append(samples, silence(rng, leadFrames*testFrame)...)
+		samples = append(samples, tone(speechFrames*testFrame, 3000)...)
+		samples = append(samples, silence(rng, tailFrames*testFrame)...)
+
+		v := NewVAD(testSampleRate, aggressiveness)
+		got := runVAD(v, samples)
+		want := []vadEventAt{
+			{leadFrames + profile.onset - 1, VADSpeechStart},
+			{leadFrames + speechFrames + profile.hangover - 1, VADSpeechEnd},
+		}
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("aggressiveness %d: events = %v, want %v", aggressiveness, got, want)
+		}
+
+		if v.InSpeech() {
+			t.Errorf("aggressiveness %d: still in speech after the hangover", aggressiveness)
+		}
+		if want := tailFrames * testFrame; v.SilenceSamples() != want {
+			t.Errorf("aggressiveness %d: SilenceSamples() = %d, want %d", aggressiveness, v.SilenceSamples(), want)
+		}
+	}
+}
+
+func TestVADHangoverBridgesPauses(t *testing.T) {
+	profile := vadProfiles[1]
+	rng := rand.New(rand.NewSource(3))
+
+	// A pause one frame shorter than the hangover keeps the segment open
+	var samples []int16
+	samples = append(samples, silence(rng, 25*testFrame)...)
+	samples = append(samples, tone(20*testFrame, 3000)...)
+	samples = append(samples, silence(rng, (profile.hangover-1)*testFrame)...)
+	samples = append(samples, tone(20*testFrame, 3000)...)
+
+	v := NewVAD(testSampleRate, 1)
+	events := runVAD(v, samples)
+	if len(events) != 1 || events[0].event != VADSpeechStart {
+		t.Fatalf("events = %v, want a single speech start", events)
+	}
+	if !v.InSpeech() {
+		t.Error("speech ended during a pause shorter than the hangover")
+	}
+
+	v.Reset()
+	if v.InSpeech() || v.HeardSpeech() || v.SilenceSamples() != 0 {
+		t.Error("Reset() kept the speech state")
+	}
+}